@@ -1,38 +1,42 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 
 	"auth-test/models"
 	"auth-test/services"
 )
 
 func NewTokenAuthorization(secret string) TokenAuthorization {
+	// 共通鍵そのものを推測されないようにハッシュの先頭をkidとする
+	sum := sha256.Sum256([]byte(secret))
 	return TokenAuthorization{
+		keyID:  base64.RawURLEncoding.EncodeToString(sum[:8]),
 		secret: secret,
 	}
 }
 
 type TokenAuthorization struct {
+	keyID  string
 	secret string
 }
 
-func (a TokenAuthorization) Sign(accessToken models.IDTokenInput) (string, error) {
-	jwtToken := jwt.New(jwt.SigningMethodHS256)
+func (a TokenAuthorization) ID() string { return a.keyID }
 
-	claims := jwtToken.Claims.(jwt.MapClaims)
-	claims["sub"] = accessToken.AccountID()
-	claims["email"] = accessToken.Email()
-	claims["iat"] = accessToken.Now().Unix()
+func (a TokenAuthorization) Algorithm() string { return AlgorithmHS256 }
 
-	exp := accessToken.ExpiredAt()
-	claims["exp"] = time.Date(
-		exp.Year(), exp.Month(), exp.Day(), exp.Hour(), exp.Minute(), exp.Second(), 0, exp.Location(),
-	).Unix()
+func (a TokenAuthorization) sign(claims jwt.MapClaims) (string, error) {
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	jwtToken.Header["kid"] = a.keyID
 	signedToken, err := jwtToken.SignedString([]byte(a.secret))
 	if err != nil {
 		return "", services.NewApplicationErr(services.FailedSingedToken, err)
@@ -41,37 +45,144 @@ func (a TokenAuthorization) Sign(accessToken models.IDTokenInput) (string, error
 	return signedToken, nil
 }
 
-func (a TokenAuthorization) Verify(token string) error {
-	signedToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+func (a TokenAuthorization) parse(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, services.NewApplicationErr(services.InvalidToken, errors.New("証明の検証に失敗しました"))
 		}
 		return []byte(a.secret), nil
 	})
+}
 
-	if err != nil {
-		return err
+func (a TokenAuthorization) marshal() ([]byte, error) { return []byte(a.secret), nil }
+
+// PublicKeys 共通鍵方式では公開できる鍵が存在しないため常に空
+func (a TokenAuthorization) PublicKeys() []models.PublicKey { return []models.PublicKey{} }
+
+func (a TokenAuthorization) renew() (signingKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, err)
 	}
+	return NewTokenAuthorization(base64.RawURLEncoding.EncodeToString(secret)), nil
+}
 
+// SupportedClaims IDトークンに含めるクレーム
+var SupportedClaims = []string{"iss", "sub", "aud", "email", "email_verified", "iat", "exp", "jti", "client_id", "scope", "roles", "org_id", "gen"}
+
+func newClaims(issuer string, accessToken models.IDTokenInput) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	claims["iss"] = issuer
+	// 失効させるトークンを特定するためのID
+	claims["jti"] = uuid.New().String()
+	claims["sub"] = accessToken.AccountID()
+	// 別のテナントで発行したトークンを利用できないよう、発行したテナントを含める
+	claims["org_id"] = accessToken.OrganizationID()
+	// client_credentialsグラントで発行するトークンは利用者を持たないためemailを含めない
+	if accessToken.Email() != "" {
+		claims["email"] = accessToken.Email()
+		claims["email_verified"] = accessToken.EmailVerified()
+	}
+	if accessToken.ClientID() != "" {
+		claims["aud"] = accessToken.ClientID()
+		claims["client_id"] = accessToken.ClientID()
+	}
+	if accessToken.Scope() != "" {
+		claims["scope"] = accessToken.Scope()
+	}
+	if len(accessToken.Roles()) > 0 {
+		claims["roles"] = accessToken.Roles()
+	}
+	// 全ての端末からログアウトした後は世代が一致しなくなり、検証に失敗する
+	if accessToken.Generation() > 0 {
+		claims["gen"] = accessToken.Generation()
+	}
+	claims["iat"] = accessToken.Now().Unix()
+
+	exp := accessToken.ExpiredAt()
+	claims["exp"] = time.Date(
+		exp.Year(), exp.Month(), exp.Day(), exp.Hour(), exp.Minute(), exp.Second(), 0, exp.Location(),
+	).Unix()
+	return claims
+}
+
+func verifyClaims(issuer string, signedToken *jwt.Token) (*models.Claims, error) {
 	if signedToken == nil {
-		return services.NewApplicationErr(services.InvalidToken, errors.New("トークンが存在しません"))
+		return nil, services.NewApplicationErr(services.InvalidToken, errors.New("トークンが存在しません"))
 	}
 
 	claims, ok := signedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return services.NewApplicationErr(services.InvalidClaim, errors.New("クレームのキャストに失敗"))
+		return nil, services.NewApplicationErr(services.InvalidClaim, errors.New("クレームのキャストに失敗"))
 	}
 
-	if _, ok = claims["sub"].(string); !ok {
-		return services.NewApplicationErr(services.InvalidIssued, errors.New("発行者のキャストに失敗"))
+	subject, ok := claims["sub"].(string)
+	if !ok {
+		return nil, services.NewApplicationErr(services.InvalidIssued, errors.New("発行者のキャストに失敗"))
+	}
+
+	// iss導入前に発行されたトークンも検証できるよう、存在する場合のみ照合する
+	if !claims.VerifyIssuer(issuer, false) {
+		return nil, services.NewApplicationErr(services.InvalidIssuer, fmt.Errorf("発行者: %s", claims["iss"]))
 	}
 
 	now := time.Now()
 	ok = claims.VerifyExpiresAt(now.Unix(), false)
 	if !ok {
-		return services.NewApplicationErr(
+		return nil, services.NewApplicationErr(
 			services.ExpiredToken, fmt.Errorf("有効期限: %s, 現在時刻: %d", claims["exp"], now.Unix()))
 	}
 
-	return nil
+	// 任意のクレームは存在しない場合に空文字やゼロ値として扱う
+	email, _ := claims["email"].(string)
+	emailVerified, _ := claims["email_verified"].(bool)
+	clientID, _ := claims["client_id"].(string)
+	scope, _ := claims["scope"].(string)
+	tokenID, _ := claims["jti"].(string)
+	// テナント導入前に発行されたトークンは既定の組織に属するものとする
+	orgID, ok := claims["org_id"].(string)
+	if !ok {
+		orgID = models.DefaultOrganizationID
+	}
+	response := models.NewClaims(
+		orgID, subject, email, clientID, scope, tokenID, emailVerified, rolesClaim(claims), intClaim(claims, "gen"),
+		unixClaim(claims, "iat"), unixClaim(claims, "exp"),
+	)
+	return &response, nil
+}
+
+func unixClaim(claims jwt.MapClaims, name string) time.Time {
+	switch v := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case json.Number:
+		n, _ := v.Int64()
+		return time.Unix(n, 0)
+	default:
+		return time.Time{}
+	}
+}
+
+func intClaim(claims jwt.MapClaims, name string) int {
+	switch v := claims[name].(type) {
+	case float64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	default:
+		return 0
+	}
+}
+
+// rolesClaim JSONの配列はinterface{}のスライスとして復元されるため文字列のみ取り出す
+func rolesClaim(claims jwt.MapClaims) []string {
+	values, _ := claims["roles"].([]interface{})
+	roles := make([]string, 0, len(values))
+	for _, v := range values {
+		if role, ok := v.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
 }
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmES384 = "ES384"
+	AlgorithmES512 = "ES512"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// NewAuthorizer 環境変数で指定された署名アルゴリズムの鍵と、保存先のローテーション済みの鍵で初期化したキーリングを生成する
+func NewAuthorizer(
+	issuer, algorithm, secret, keyPath string, retention time.Duration, repo models.SigningKeyAccessor,
+) (*KeyRing, error) {
+	key, err := newSigningKey(algorithm, secret, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeyRing(issuer, key, retention, repo, secret)
+}
+
+func newSigningKey(algorithm, secret, keyPath string) (signingKey, error) {
+	switch algorithm {
+	case AlgorithmHS256:
+		return NewTokenAuthorization(secret), nil
+	case AlgorithmRS256:
+		return NewRSAAuthorization(keyPath)
+	case AlgorithmES256, AlgorithmES384, AlgorithmES512:
+		a, err := NewECDSAAuthorization(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		if a.method.Alg() != algorithm {
+			return nil, services.NewApplicationErr(
+				services.InvalidSigningKey,
+				fmt.Errorf("鍵の曲線は %s 用です: %s", a.method.Alg(), keyPath),
+			)
+		}
+		return a, nil
+	case AlgorithmEdDSA:
+		return NewEd25519Authorization(keyPath)
+	default:
+		return nil, services.NewApplicationErr(services.UnknownAlgorithm, errors.New(algorithm))
+	}
+}
+
+func NewRSAAuthorization(keyPath string) (KeyAuthorization, error) {
+	pem, err := os.ReadFile(keyPath)
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	return newKeyAuthorization(jwt.SigningMethodRS256, key)
+}
+
+func NewECDSAAuthorization(keyPath string) (KeyAuthorization, error) {
+	pem, err := os.ReadFile(keyPath)
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	var method jwt.SigningMethod
+	switch key.Curve {
+	case elliptic.P256():
+		method = jwt.SigningMethodES256
+	case elliptic.P384():
+		method = jwt.SigningMethodES384
+	case elliptic.P521():
+		method = jwt.SigningMethodES512
+	default:
+		return KeyAuthorization{}, services.NewApplicationErr(
+			services.InvalidSigningKey, fmt.Errorf("未対応の曲線です: %s", key.Curve.Params().Name),
+		)
+	}
+
+	return newKeyAuthorization(method, key)
+}
+
+func NewEd25519Authorization(keyPath string) (KeyAuthorization, error) {
+	pem, err := os.ReadFile(keyPath)
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return KeyAuthorization{}, services.NewApplicationErr(
+			services.InvalidSigningKey, errors.New("Ed25519の秘密鍵ではありません"),
+		)
+	}
+
+	return newKeyAuthorization(jwt.SigningMethodEdDSA, edKey)
+}
+
+func newKeyAuthorization(method jwt.SigningMethod, key crypto.Signer) (KeyAuthorization, error) {
+	keyID, err := thumbprint(key.Public())
+	if err != nil {
+		return KeyAuthorization{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	return KeyAuthorization{
+		method: method,
+		keyID:  keyID,
+		key:    key,
+	}, nil
+}
+
+// KeyAuthorization 秘密鍵で署名し、公開鍵で検証する非対称鍵方式のAuthorizer
+type KeyAuthorization struct {
+	method jwt.SigningMethod
+	keyID  string
+	key    crypto.Signer
+}
+
+func (a KeyAuthorization) ID() string { return a.keyID }
+
+func (a KeyAuthorization) Algorithm() string { return a.method.Alg() }
+
+func (a KeyAuthorization) sign(claims jwt.MapClaims) (string, error) {
+	jwtToken := jwt.NewWithClaims(a.method, claims)
+	jwtToken.Header["kid"] = a.keyID
+	signedToken, err := jwtToken.SignedString(a.key)
+	if err != nil {
+		return "", services.NewApplicationErr(services.FailedSingedToken, err)
+	}
+
+	return signedToken, nil
+}
+
+func (a KeyAuthorization) parse(token string) (*jwt.Token, error) {
+	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		if t.Method.Alg() != a.method.Alg() {
+			return nil, services.NewApplicationErr(services.InvalidToken, errors.New("証明の検証に失敗しました"))
+		}
+		return a.key.Public(), nil
+	})
+}
+
+func (a KeyAuthorization) PublicKeys() []models.PublicKey {
+	return []models.PublicKey{models.NewPublicKey(a.keyID, a.method.Alg(), a.key.Public())}
+}
+
+func (a KeyAuthorization) marshal() ([]byte, error) { return x509.MarshalPKCS8PrivateKey(a.key) }
+
+// renew 同じアルゴリズムの鍵ペアを新たに生成する
+func (a KeyAuthorization) renew() (signingKey, error) {
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch k := a.key.(type) {
+	case *rsa.PrivateKey:
+		key, err = rsa.GenerateKey(rand.Reader, k.N.BitLen())
+	case *ecdsa.PrivateKey:
+		key, err = ecdsa.GenerateKey(k.Curve, rand.Reader)
+	case ed25519.PrivateKey:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("未対応の秘密鍵です: %T", a.key)
+	}
+	if err != nil {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	return newKeyAuthorization(a.method, key)
+}
+
+// thumbprint 公開鍵のDERからSHA-256のダイジェストを求め、kidとして利用する
+func thumbprint(key crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// minReloadInterval 未知のkidを提示されるたびに保存先を参照しないよう、読み込み直す間隔を空ける
+const minReloadInterval = time.Second
+
+// signingKey キーリングで管理する1つの鍵
+type signingKey interface {
+	ID() string
+	Algorithm() string
+	PublicKeys() []models.PublicKey
+	sign(jwt.MapClaims) (string, error)
+	parse(string) (*jwt.Token, error)
+	renew() (signingKey, error)
+	marshal() ([]byte, error)
+}
+
+type retiredKey struct {
+	key       signingKey
+	retiredAt time.Time
+}
+
+// NewKeyRing 保存先からローテーション済みの鍵を読み込んだキーリングを生成する
+// secretはローテーションで生成した鍵を保存先で暗号化するために使う
+func NewKeyRing(
+	issuer string, configured signingKey, retention time.Duration, repo models.SigningKeyAccessor, secret string,
+) (*KeyRing, error) {
+	r := &KeyRing{
+		issuer:     issuer,
+		configured: configured,
+		retention:  retention,
+		repo:       repo,
+		secret:     secret,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// KeyRing 署名に使う鍵を1つと、ローテーション済みで検証にのみ使う鍵を保持する
+// ローテーションした鍵は保存先で共有するため、再起動後や他のインスタンスでも同じ鍵で検証できる
+type KeyRing struct {
+	issuer     string
+	configured signingKey
+	retention  time.Duration
+	repo       models.SigningKeyAccessor
+	secret     string
+	mu         sync.RWMutex
+	active     signingKey
+	retired    []retiredKey
+	// refreshedAt 未知のkidを検証するために読み込み直した日時
+	refreshedAt time.Time
+}
+
+func (r *KeyRing) Sign(accessToken models.IDTokenInput) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.active.sign(newClaims(r.issuer, accessToken))
+}
+
+func (r *KeyRing) Verify(token string) (*models.Claims, error) {
+	key, err := r.find(token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	signedToken, err := key.parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifyClaims(r.issuer, signedToken)
+}
+
+func (r *KeyRing) PublicKeys() []models.PublicKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	keys := r.active.PublicKeys()
+	for _, k := range r.retired {
+		if now.Before(k.retiredAt) {
+			keys = append(keys, k.key.PublicKeys()...)
+		}
+	}
+	return keys
+}
+
+// Rotate 新しい鍵を保存して署名に使うように切り替え、旧鍵はIDトークンの有効期間だけ検証用に残す
+func (r *KeyRing) Rotate(now time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.active.renew()
+	if err != nil {
+		return "", err
+	}
+	stored, err := sealSigningKey(r.secret, next, now)
+	if err != nil {
+		return "", err
+	}
+
+	// 他のインスタンスがローテーションした鍵も退役させるため、保存先の状態から読み込み直す
+	if err = r.repo.Rotate(stored, now.Add(r.retention)); err != nil {
+		return "", err
+	}
+	if err = r.load(now); err != nil {
+		return "", err
+	}
+	return next.ID(), nil
+}
+
+// Reload 保存先から鍵を読み込み直し、他のインスタンスでローテーションした鍵を反映する
+func (r *KeyRing) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.load(time.Now())
+}
+
+// Watch intervalごとに鍵を読み込み直し、他のインスタンスでローテーションした鍵で署名するように切り替える
+// 読み込みに失敗した場合は直前の鍵を使い続ける
+func (r *KeyRing) Watch(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				log.Printf("署名鍵の再読み込みに失敗。: %s \n", err.Error())
+			}
+		}
+	}
+}
+
+// load 保存した鍵のうち最後のものを署名に使い、それ以前の鍵は退役日時まで検証にのみ使う
+// 設定された鍵は最初にローテーションした時点で退役させる。ロックを取得した状態で呼び出す
+func (r *KeyRing) load(now time.Time) error {
+	stored, err := r.repo.List()
+	if err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		r.active, r.retired = r.configured, nil
+		return nil
+	}
+
+	retired := []retiredKey{}
+	if configuredRetiredAt := stored[0].CreatedAt().Add(r.retention); now.Before(configuredRetiredAt) {
+		retired = append(retired, retiredKey{key: r.configured, retiredAt: configuredRetiredAt})
+	}
+	for _, s := range stored[:len(stored)-1] {
+		if !now.Before(s.RetiredAt()) {
+			continue
+		}
+		key, err := openSigningKey(r.secret, s)
+		if err != nil {
+			return err
+		}
+		retired = append(retired, retiredKey{key: key, retiredAt: s.RetiredAt()})
+	}
+
+	active, err := openSigningKey(r.secret, stored[len(stored)-1])
+	if err != nil {
+		return err
+	}
+	r.active, r.retired = active, retired
+	return nil
+}
+
+// find ヘッダのkidから検証に使う鍵を選ぶ。kidが無い場合は現在の署名鍵を使う
+// 見つからない場合は他のインスタンスでローテーションした鍵の可能性があるため、読み込み直して探す
+func (r *KeyRing) find(token string, now time.Time) (signingKey, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return nil, services.NewApplicationErr(services.InvalidToken, err)
+	}
+
+	kid, ok := unverified.Header["kid"]
+	if !ok {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.active, nil
+	}
+
+	keyID, ok := kid.(string)
+	if !ok {
+		return nil, services.NewApplicationErr(services.InvalidToken, errors.New("kidのキャストに失敗"))
+	}
+
+	if key, ok := r.lookup(keyID, now); ok {
+		return key, nil
+	}
+	if r.refresh(now) {
+		if key, ok := r.lookup(keyID, now); ok {
+			return key, nil
+		}
+	}
+
+	return nil, services.NewApplicationErr(services.UnknownSigningKey, fmt.Errorf("kid: %s", keyID))
+}
+
+func (r *KeyRing) lookup(keyID string, now time.Time) (signingKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if keyID == r.active.ID() {
+		return r.active, true
+	}
+	for _, k := range r.retired {
+		if k.key.ID() == keyID && now.Before(k.retiredAt) {
+			return k.key, true
+		}
+	}
+	return nil, false
+}
+
+// refresh 前回読み込み直してからminReloadInterval以上経過した場合のみ読み込み直す
+func (r *KeyRing) refresh(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.refreshedAt) < minReloadInterval {
+		return false
+	}
+	r.refreshedAt = now
+	if err := r.load(now); err != nil {
+		log.Printf("署名鍵の再読み込みに失敗。: %s \n", err.Error())
+		return false
+	}
+	return true
+}
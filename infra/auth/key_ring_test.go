@@ -0,0 +1,133 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+
+	"auth-test/infra/auth"
+	"auth-test/infra/memory"
+	"auth-test/models"
+)
+
+const (
+	testIssuer = "http://localhost:8080"
+	testSecret = "secret"
+)
+
+func writeECKey(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("鍵の生成に失敗: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("鍵の変換に失敗: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "ec.pem")
+	if err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("鍵の保存に失敗: %v", err)
+	}
+	return path
+}
+
+func sign(t *testing.T, ring *auth.KeyRing) string {
+	t.Helper()
+	now := time.Now()
+	token, err := ring.Sign(models.NewAccessTokenInput(
+		models.DefaultOrganizationID, uuid.New().String(), "user@example.com", "", "", true, nil, 0, now,
+		now.Add(time.Hour),
+	))
+	if err != nil {
+		t.Fatalf("署名に失敗: %v", err)
+	}
+	return token
+}
+
+func keyID(t *testing.T, token string) string {
+	t.Helper()
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatalf("トークンの解析に失敗: %v", err)
+	}
+	kid, _ := unverified.Header["kid"].(string)
+	return kid
+}
+
+func TestKeyRingSharesRotatedKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		keyPath   func(t *testing.T) string
+	}{
+		{name: "共通鍵", algorithm: auth.AlgorithmHS256, keyPath: func(*testing.T) string { return "" }},
+		{name: "楕円曲線暗号", algorithm: auth.AlgorithmES256, keyPath: writeECKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			keyPath := tt.keyPath(t)
+			newRing := func(secret string) (*auth.KeyRing, error) {
+				return auth.NewAuthorizer(
+					testIssuer, tt.algorithm, secret, keyPath, time.Hour, memory.NewSigningKeyRepository(store),
+				)
+			}
+
+			rotating, err := newRing(testSecret)
+			if err != nil {
+				t.Fatalf("キーリングの生成に失敗: %v", err)
+			}
+			other, err := newRing(testSecret)
+			if err != nil {
+				t.Fatalf("キーリングの生成に失敗: %v", err)
+			}
+
+			before := sign(t, rotating)
+			rotatedID, err := rotating.Rotate(time.Now())
+			if err != nil {
+				t.Fatalf("ローテーションに失敗: %v", err)
+			}
+			after := sign(t, rotating)
+			if keyID(t, after) != rotatedID || rotatedID == keyID(t, before) {
+				t.Fatalf("ローテーションした鍵で署名していません: %s", keyID(t, after))
+			}
+
+			// 別のインスタンスもローテーションした鍵とローテーション前の鍵の両方で検証できる
+			for _, token := range []string{after, before} {
+				if _, err = other.Verify(token); err != nil {
+					t.Fatalf("別のインスタンスで検証に失敗: %v", err)
+				}
+			}
+
+			// 再起動後も保存した鍵で署名し、ローテーション前の鍵で署名したトークンも検証できる
+			restarted, err := newRing(testSecret)
+			if err != nil {
+				t.Fatalf("キーリングの生成に失敗: %v", err)
+			}
+			if kid := keyID(t, sign(t, restarted)); kid != rotatedID {
+				t.Fatalf("再起動後に設定された鍵に戻っています: %s", kid)
+			}
+			for _, token := range []string{after, before} {
+				if _, err = restarted.Verify(token); err != nil {
+					t.Fatalf("再起動後に検証に失敗: %v", err)
+				}
+			}
+
+			// 異なるENCRYPT_SECRETでは保存した鍵を復号できない
+			if _, err = newRing("other"); err == nil {
+				t.Fatalf("異なるシークレットで保存した鍵を読み込めてしまいます")
+			}
+		})
+	}
+}
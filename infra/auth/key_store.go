@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// sealSigningKey 保存先から漏れた鍵で署名できないよう、ENCRYPT_SECRETから導出した鍵で暗号化する
+func sealSigningKey(secret string, key signingKey, now time.Time) (models.SigningKey, error) {
+	plaintext, err := key.marshal()
+	if err != nil {
+		return models.SigningKey{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	aead, err := newKeyCipher(secret)
+	if err != nil {
+		return models.SigningKey{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return models.SigningKey{}, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	// 別の鍵の暗号文と入れ替えられないよう、kidを追加データとして認証する
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(key.ID()))
+	return models.NewSigningKey(
+		key.ID(), key.Algorithm(), base64.RawURLEncoding.EncodeToString(sealed), now, time.Time{},
+	), nil
+}
+
+// openSigningKey 保存した鍵を復号し、署名と検証に使える鍵に戻す
+func openSigningKey(secret string, stored models.SigningKey) (signingKey, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(stored.Material())
+	if err != nil {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+
+	aead, err := newKeyCipher(secret)
+	if err != nil {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, fmt.Errorf("kid: %s", stored.ID()))
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(stored.ID()))
+	if err != nil {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, fmt.Errorf("kid: %s: %w", stored.ID(), err))
+	}
+
+	key, err := unmarshalSigningKey(stored.Algorithm(), plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID() != stored.ID() {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, fmt.Errorf("kid: %s", stored.ID()))
+	}
+	return key, nil
+}
+
+func newKeyCipher(secret string) (cipher.AEAD, error) {
+	sum := sha256.Sum256([]byte("signing-key:" + secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// unmarshalSigningKey 共通鍵はそのまま、秘密鍵はPKCS #8のDERとして復元する
+func unmarshalSigningKey(algorithm string, material []byte) (signingKey, error) {
+	if algorithm == AlgorithmHS256 {
+		return NewTokenAuthorization(string(material)), nil
+	}
+
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, services.NewApplicationErr(services.UnknownAlgorithm, errors.New(algorithm))
+	}
+	key, err := x509.ParsePKCS8PrivateKey(material)
+	if err != nil {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, services.NewApplicationErr(services.InvalidSigningKey, fmt.Errorf("未対応の秘密鍵です: %T", key))
+	}
+	return newKeyAuthorization(method, signer)
+}
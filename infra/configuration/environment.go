@@ -3,13 +3,46 @@ package configuration
 import "time"
 
 type Environment struct {
+	Store             string        `envconfig:"STORE" default:"mysql"`
 	User              string        `default:"root"`
-	Password          string        `required:"true"`
+	Password          string        `envconfig:"PASSWORD"`
 	Host              string        `default:"0.0.0.0"`
 	Port              int           `default:"3306"`
 	Name              string        `default:"auth_test"`
 	EncryptSecret     string        `envconfig:"ENCRYPT_SECRET" required:"true"`
+	Issuer            string        `envconfig:"ISSUER" default:"http://localhost:8080"`
+	SigningAlgorithm  string        `envconfig:"SIGNING_ALGORITHM" default:"HS256"`
+	SigningKeyPath    string        `envconfig:"SIGNING_KEY_PATH"`
+	KeyInterval       time.Duration `envconfig:"SIGNING_KEY_RELOAD_INTERVAL" default:"10s"`
+	AdminToken        string        `envconfig:"ADMIN_TOKEN"`
+	AdminEmail        string        `envconfig:"ADMIN_EMAIL"`
+	PolicyPath        string        `envconfig:"POLICY_PATH"`
+	PolicyInterval    time.Duration `envconfig:"POLICY_RELOAD_INTERVAL" default:"10s"`
+	PATMaxExpiration  time.Duration `envconfig:"PAT_MAX_EXPIRATION" default:"8760h"`
+	SessionCookie     bool          `envconfig:"SESSION_COOKIE"`
+	CookieName        string        `envconfig:"SESSION_COOKIE_NAME" default:"session_id"`
+	CookieDomain      string        `envconfig:"SESSION_COOKIE_DOMAIN"`
+	CookiePath        string        `envconfig:"SESSION_COOKIE_PATH" default:"/"`
 	RefreshExpiration time.Duration `default:"1h"`
 	AccessExpiration  time.Duration `default:"10m"`
-	SessionExpiration time.Duration `default:"1h"`
+	SessionExpiration time.Duration `default:"12h"`
+	SessionIdle       time.Duration `envconfig:"SESSION_IDLE_TIMEOUT" default:"30m"`
+	SessionTouch      time.Duration `envconfig:"SESSION_TOUCH_INTERVAL" default:"1m"`
+	SessionLimit      int           `envconfig:"SESSION_LIMIT"`
+	LimitPolicy       string        `envconfig:"SESSION_LIMIT_POLICY" default:"reject"`
+	GCInterval        time.Duration `envconfig:"GC_INTERVAL" default:"10m"`
+	GCBatchSize       int           `envconfig:"GC_BATCH_SIZE" default:"1000"`
+	Mailer            string        `envconfig:"MAILER" default:"log"`
+	MailFrom          string        `envconfig:"MAIL_FROM" default:"no-reply@localhost"`
+	MailLogPath       string        `envconfig:"MAIL_LOG_PATH"`
+	SMTPHost          string        `envconfig:"SMTP_HOST"`
+	SMTPPort          int           `envconfig:"SMTP_PORT" default:"587"`
+	SMTPUser          string        `envconfig:"SMTP_USERNAME"`
+	SMTPPassword      string        `envconfig:"SMTP_PASSWORD"`
+	VerifyURL         string        `envconfig:"EMAIL_VERIFY_URL" default:"http://localhost:8080/verify"`
+	VerifyExpiration  time.Duration `envconfig:"EMAIL_VERIFY_EXPIRATION" default:"24h"`
+	RequireVerified   bool          `envconfig:"REQUIRE_VERIFIED_EMAIL"`
+	ResetURL          string        `envconfig:"PASSWORD_RESET_URL" default:"http://localhost:8080/reset"`
+	ResetExpiration   time.Duration `envconfig:"PASSWORD_RESET_EXPIRATION" default:"30m"`
+	CodeExpiration    time.Duration `default:"1m"`
 }
@@ -0,0 +1,285 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// adminTokenSubject 環境変数のトークンで認証した運用者を表す主体
+const adminTokenSubject = "admin-token"
+
+func NewAdminHandler(token string, verifier services.Authorizer, svc services.UserAdministration) AdminHandler {
+	return AdminHandler{
+		token:    token,
+		verifier: verifier,
+		service:  svc,
+	}
+}
+
+// AdminHandler 管理者ロールを持つ主体のみが利用できる運用者向けAPI
+type AdminHandler struct {
+	token    string
+	verifier services.Authorizer
+	service  services.UserAdministration
+}
+
+type inputAdminUserAccount struct {
+	Email    string `json:"email" binding:"required,email" example:"test@example.com"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"omitempty,min=8,max=72,nist_sp_800_63" minLength:"8" maxLength:"72" example:"string"`
+}
+
+type adminUserAccountResponse struct {
+	ID            string     `json:"id" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	Email         string     `json:"email" example:"test@example.com"`
+	Name          string     `json:"name"`
+	ResetRequired bool       `json:"reset_required"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+}
+
+type adminSessionResponse struct {
+	Fingerprint string    `json:"fingerprint"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiredAt   time.Time `json:"expired_at"`
+}
+
+type adminRefreshTokenResponse struct {
+	Fingerprint       string    `json:"fingerprint"`
+	FamilyFingerprint string    `json:"family_fingerprint"`
+	ClientID          string    `json:"client_id,omitempty"`
+	Scope             string    `json:"scope,omitempty"`
+	IssuedAt          time.Time `json:"issued_at"`
+	ExpiredAt         time.Time `json:"expired_at"`
+}
+
+// Authenticate 環境変数のトークン、または管理者ロールを持つIDトークンで認証する
+// 環境変数のトークンは管理者ロールを持つ主体として扱う
+func (h AdminHandler) Authenticate(c *gin.Context) {
+	t := c.GetHeader("Authorization")
+
+	token := strings.Replace(t, "Bearer ", "", 1)
+	if "" == token {
+		c.AbortWithStatusJSON(
+			http.StatusUnauthorized,
+			errResponse{Message: services.EmptyToken.Error(), Detail: "トークンは必須です"},
+		)
+		return
+	}
+
+	if h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1 {
+		setPrincipal(c, models.NewPrincipal(
+			CurrentOrganization(c), adminTokenSubject, "", []string{}, "", []string{models.RoleAdmin},
+		))
+		c.Next()
+		return
+	}
+
+	principal, err := h.verifier.Verify(CurrentOrganization(c), token)
+	if err != nil {
+		status, response := newErrResponse(err, "")
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	setPrincipal(c, *principal)
+	c.Next()
+}
+
+// CreateUser create user account by admin
+// @Summary Create a user account. Without password, the user must reset it before login
+// @Tags Admin
+// @Param inputAdminUserAccount body controller.inputAdminUserAccount true "Email, UserName and optional Password"
+// @Produce json
+// @Success 200 {object} controller.adminUserAccountResponse
+// @Failure default {object} controller.errResponse
+// @Router /admin/users [post]
+// @Security Bearer
+func (h AdminHandler) CreateUser(c *gin.Context) {
+	var input inputAdminUserAccount
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	account, err := h.service.Create(
+		models.NewUserAccount(CurrentOrganization(c), uuid.New().String(), input.Email, input.Name, input.Password),
+	)
+	if err != nil {
+		status, response := newErrResponse(err, input.Email)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAdminUserAccountResponse(*account))
+}
+
+// DisableUser disable user account
+// @Summary Disable a user account and delete its sessions and refresh tokens
+// @Tags Admin
+// @Param id path string true "User ID by UUID"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /admin/users/{id}/disable [post]
+// @Security Bearer
+func (h AdminHandler) DisableUser(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	if err := h.service.Disable(CurrentOrganization(c), params.ID, time.Now().UTC()); err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// EnableUser enable user account
+// @Summary Enable a disabled user account
+// @Tags Admin
+// @Param id path string true "User ID by UUID"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /admin/users/{id}/enable [post]
+// @Security Bearer
+func (h AdminHandler) EnableUser(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	if err := h.service.Enable(CurrentOrganization(c), params.ID); err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ForceLogout delete all sessions and refresh tokens of user
+// @Summary Delete all sessions and refresh tokens of a user
+// @Tags Admin
+// @Param id path string true "User ID by UUID"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /admin/users/{id}/sessions [delete]
+// @Security Bearer
+func (h AdminHandler) ForceLogout(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	if err := h.service.ForceLogout(CurrentOrganization(c), params.ID, time.Now().UTC()); err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListSessions get sessions of user
+// @Summary Return active sessions of a user. Session tokens are shown as fingerprints
+// @Tags Admin
+// @Param id path string true "User ID by UUID"
+// @Produce json
+// @Success 200 {object} []controller.adminSessionResponse
+// @Failure default {object} controller.errResponse
+// @Router /admin/users/{id}/sessions [get]
+// @Security Bearer
+func (h AdminHandler) ListSessions(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	sessions, err := h.service.Sessions(CurrentOrganization(c), params.ID)
+	if err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	response := make([]adminSessionResponse, 0, len(sessions))
+	for _, sess := range sessions {
+		response = append(response, adminSessionResponse{
+			Fingerprint: models.Fingerprint(sess.Token()),
+			CreatedAt:   sess.CreatedAt(),
+			ExpiredAt:   sess.ExpiredAt(),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListRefreshTokens get refresh tokens of user
+// @Summary Return active refresh tokens of a user. Tokens are shown as fingerprints
+// @Tags Admin
+// @Param id path string true "User ID by UUID"
+// @Produce json
+// @Success 200 {object} []controller.adminRefreshTokenResponse
+// @Failure default {object} controller.errResponse
+// @Router /admin/users/{id}/tokens [get]
+// @Security Bearer
+func (h AdminHandler) ListRefreshTokens(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	tokens, err := h.service.RefreshTokens(CurrentOrganization(c), params.ID)
+	if err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	response := make([]adminRefreshTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, adminRefreshTokenResponse{
+			Fingerprint:       models.Fingerprint(token.Value()),
+			FamilyFingerprint: models.Fingerprint(token.FamilyID()),
+			ClientID:          token.ClientID(),
+			Scope:             token.Scope(),
+			IssuedAt:          token.IssuedAt(),
+			ExpiredAt:         token.ExpiredAt(),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func newAdminUserAccountResponse(account models.UserAccount) adminUserAccountResponse {
+	response := adminUserAccountResponse{
+		ID:            account.ID(),
+		Email:         account.Email(),
+		Name:          account.Name(),
+		ResetRequired: account.ResetRequired(),
+	}
+	if account.IsDisabled() {
+		disabledAt := account.DisabledAt()
+		response.DisabledAt = &disabledAt
+	}
+	return response
+}
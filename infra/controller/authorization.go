@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -9,6 +10,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 
+	"auth-test/models"
 	"auth-test/services"
 )
 
@@ -48,7 +50,7 @@ func (h TokenHandler) Claim(c *gin.Context) {
 		return
 	}
 
-	token, err := h.authenticateSvc.Claim(form.Email, form.Password, uuid.New().String(), time.Now())
+	token, err := h.authenticateSvc.Claim(CurrentOrganization(c), form.Email, form.Password, uuid.New().String(), time.Now())
 	if err != nil {
 		status, response := newErrResponse(err, form.Email)
 		c.AbortWithStatusJSON(status, response)
@@ -76,7 +78,8 @@ func (h TokenHandler) Refresh(c *gin.Context) {
 	}
 
 	now := time.Now()
-	token, err := h.authenticateSvc.Refresh(uuid.New().String(), refresh.Value, now.UTC())
+	// Claimで発行したクライアントを持たないトークンのみ更新できる
+	token, err := h.authenticateSvc.Refresh(CurrentOrganization(c), "", "", uuid.New().String(), refresh.Value, now.UTC())
 	if err != nil {
 		status, response := newErrResponse(err, refresh.Value)
 		c.AbortWithStatusJSON(status, response)
@@ -98,12 +101,102 @@ func (h TokenHandler) VerifyIDToken(c *gin.Context) {
 		return
 	}
 
-	err := h.authenticateSvc.Verify(token)
+	principal, err := h.authenticateSvc.Verify(CurrentOrganization(c), token)
 	if err != nil {
 		status, response := newErrResponse(err, "")
 		c.AbortWithStatusJSON(status, response)
 		return
 	}
 
+	setPrincipal(c, *principal)
 	c.Next()
 }
+
+// JWKS get public keys
+// @Summary Return public keys to verify id token
+// @Tags JWKS
+// @Produce json
+// @Success 200 {object} controller.jsonWebKeySet
+// @Router  /.well-known/jwks.json [get]
+func (h TokenHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, newJSONWebKeySet(h.authenticateSvc.PublicKeys()))
+}
+
+type signingKeyResponse struct {
+	KeyID string `json:"kid"`
+}
+
+// RotateKey rotate signing key
+// @Summary Switch to a new signing key and keep the old one for verification
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} controller.signingKeyResponse
+// @Failure default {object} controller.errResponse
+// @Router  /admin/keys/rotate [post]
+// @Security Bearer
+func (h TokenHandler) RotateKey(c *gin.Context) {
+	keyID, err := h.authenticateSvc.RotateKey(time.Now())
+	if err != nil {
+		status, response := newErrResponse(err, "")
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, signingKeyResponse{KeyID: keyID})
+}
+
+// userInfoResponse 許可されたスコープに含まれないクレームは返さない
+type userInfoResponse struct {
+	Sub           string `json:"sub" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	Email         string `json:"email,omitempty" example:"test@example.com"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+	UpdatedAt     int64  `json:"updated_at,omitempty"`
+}
+
+// UserInfo get claims of the token owner
+// @Summary Return OpenID Connect standard claims filtered by granted scope
+// @Tags UserInfo
+// @Produce json
+// @Success 200 {object} controller.userInfoResponse
+// @Failure default {object} controller.errResponse
+// @Router  /auth/userinfo [get]
+// @Security Bearer
+func (h TokenHandler) UserInfo(c *gin.Context) {
+	t := c.GetHeader("Authorization")
+
+	token := strings.Replace(t, "Bearer ", "", 1)
+	if "" == token {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatusJSON(
+			http.StatusUnauthorized,
+			errResponse{Message: services.EmptyToken.Error(), Detail: "トークンは必須です"},
+		)
+		return
+	}
+
+	info, err := h.authenticateSvc.UserInfo(CurrentOrganization(c), token)
+	if err != nil {
+		status, response := newErrResponse(err, "")
+		if errors.Is(err, services.FailedAuthenticate) {
+			status = http.StatusUnauthorized
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		}
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	account := info.Account()
+	response := userInfoResponse{Sub: account.ID()}
+	if info.Allows(models.ScopeEmail) {
+		verified := account.EmailVerified()
+		response.Email = account.Email()
+		response.EmailVerified = &verified
+	}
+	if info.Allows(models.ScopeProfile) {
+		response.Name = account.Name()
+		response.UpdatedAt = account.UpdatedAt().Unix()
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewClientHandler(registry services.ClientRegistry) ClientHandler {
+	return ClientHandler{
+		registry: registry,
+	}
+}
+
+type ClientHandler struct {
+	registry services.ClientRegistry
+}
+
+type inputClient struct {
+	Name         string   `json:"name" binding:"required"`
+	RedirectURIs []string `json:"redirect_uris" binding:"dive,url" example:"https://example.com/callback"`
+	GrantTypes   []string `json:"grant_types" binding:"required,min=1,dive,oneof=authorization_code refresh_token client_credentials" example:"authorization_code"`
+	Scopes       []string `json:"scopes" example:"openid"`
+	Public       bool     `json:"public"`
+}
+
+type clientResponse struct {
+	ClientID     string   `json:"client_id" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+}
+
+// Create register client
+// @Summary Register an OAuth client. client_secret is shown only in this response
+// @Tags Admin
+// @Param inputClient body controller.inputClient true "Client metadata"
+// @Produce json
+// @Success 200 {object} controller.clientResponse
+// @Failure default {object} controller.errResponse
+// @Router /admin/clients [post]
+// @Security Bearer
+func (h ClientHandler) Create(c *gin.Context) {
+	var input inputClient
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	var secret string
+	if !input.Public {
+		s, err := newClientSecret()
+		if err != nil {
+			c.AbortWithStatusJSON(
+				http.StatusInternalServerError,
+				newValidationErr(services.InternalServerErr.Error(), err.Error()),
+			)
+			return
+		}
+		secret = s
+	}
+
+	client, err := h.registry.Register(
+		models.NewClient(uuid.New().String(), input.Name, secret, input.RedirectURIs, input.GrantTypes, input.Scopes),
+	)
+	if err != nil {
+		status, response := newErrResponse(err, input.Name)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, clientResponse{
+		ClientID:     client.ID(),
+		ClientSecret: secret,
+		Name:         client.Name(),
+		RedirectURIs: client.RedirectURIs(),
+		GrantTypes:   client.GrantTypes(),
+		Scopes:       client.Scopes(),
+	})
+}
+
+func newClientSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenIDConfiguration OpenID Connect Discovery 1.0 で定義されたプロバイダのメタデータ
+type OpenIDConfiguration struct {
+	Issuer                            string   `json:"issuer" example:"http://localhost:8080"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                     string   `json:"token_endpoint,omitempty"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	RevocationEndpoint                string   `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+}
+
+func NewDiscoveryHandler(config OpenIDConfiguration) DiscoveryHandler {
+	return DiscoveryHandler{
+		config: config,
+	}
+}
+
+type DiscoveryHandler struct {
+	config OpenIDConfiguration
+}
+
+// OpenIDConfiguration get provider metadata
+// swaggerのBasePath(/v1)の外に公開するためswaggerコメントは定義しない
+func (h DiscoveryHandler) OpenIDConfiguration(c *gin.Context) {
+	c.JSON(http.StatusOK, h.config)
+}
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-test/services"
+)
+
+func NewEmailVerificationHandler(service services.EmailVerifier) EmailVerificationHandler {
+	return EmailVerificationHandler{
+		service: service,
+	}
+}
+
+type EmailVerificationHandler struct {
+	service services.EmailVerifier
+}
+
+type inputEmailVerification struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type inputResendVerification struct {
+	Email string `json:"email" binding:"required,email" example:"test@example.com"`
+}
+
+// Verify mark email address as verified
+// @Summary Verify the email address of a user with the token sent in the verification mail
+// @Tags UserAccount
+// @Param inputEmailVerification body controller.inputEmailVerification true "Token in the verification link"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /users/verify [post]
+func (h EmailVerificationHandler) Verify(c *gin.Context) {
+	var input inputEmailVerification
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	if err := h.service.Verify(input.Token, time.Now().UTC()); err != nil {
+		status, response := newErrResponse(err, "")
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Resend send verification mail again
+// @Summary Send the verification mail again. Returns 202 whether or not the email address is registered
+// @Tags UserAccount
+// @Param inputResendVerification body controller.inputResendVerification true "Email"
+// @Success 202
+// @Failure 400 {object} controller.errResponse
+// @Router /users/verify/resend [post]
+func (h EmailVerificationHandler) Resend(c *gin.Context) {
+	var input inputResendVerification
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	// 送信の失敗や送信にかかる時間から登録済みのメールアドレスを推測されないよう、
+	// レスポンスを返した後に送信し、失敗はログにのみ出力する
+	orgID := CurrentOrganization(c)
+	go func() {
+		if err := h.service.Resend(orgID, input.Email, time.Now().UTC()); err != nil {
+			log.Printf("確認メールの再送に失敗。: %s: %v \n", input.Email, rootCause(err))
+		}
+	}()
+
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,426 @@
+package controller
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// RFC 6749 5.2 で定義されたエラーコード
+const (
+	oauthInvalidRequest       = "invalid_request"
+	oauthInvalidClient        = "invalid_client"
+	oauthInvalidGrant         = "invalid_grant"
+	oauthUnauthorizedClient   = "unauthorized_client"
+	oauthUnsupportedGrantType = "unsupported_grant_type"
+	oauthInvalidScope         = "invalid_scope"
+	oauthServerError          = "server_error"
+)
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>ログイン</title></head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="response_type" value="{{.Form.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Form.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Form.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Form.Scope}}">
+<input type="hidden" name="state" value="{{.Form.State}}">
+<input type="hidden" name="code_challenge" value="{{.Form.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Form.CodeChallengeMethod}}">
+<label>Email <input type="email" name="email" required></label>
+<label>Password <input type="password" name="password" required></label>
+<button type="submit">ログイン</button>
+</form>
+</body>
+</html>
+`))
+
+type authorizeParams struct {
+	ResponseType        string `form:"response_type" binding:"required,eq=code"`
+	ClientID            string `form:"client_id" binding:"required"`
+	RedirectURI         string `form:"redirect_uri" binding:"required,url"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	CodeChallenge       string `form:"code_challenge" binding:"required,min=43,max=128"`
+	CodeChallengeMethod string `form:"code_challenge_method" binding:"required,eq=S256"`
+}
+
+type authorizeForm struct {
+	authorizeParams
+	Email    string `form:"email" binding:"required,email"`
+	Password string `form:"password" binding:"required"`
+}
+
+type tokenForm struct {
+	GrantType    string `form:"grant_type" binding:"required"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Scope        string `form:"scope"`
+	CodeVerifier string `form:"code_verifier" binding:"omitempty,min=43,max=128"`
+	RefreshToken string `form:"refresh_token"`
+}
+
+type revokeForm struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" binding:"omitempty,oneof=access_token refresh_token"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type introspectForm struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint" binding:"omitempty,oneof=access_token refresh_token"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+// introspectionResponse 無効なトークンの場合はactive以外を返さない
+type introspectionResponse struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty" example:"access_token"`
+	Sub       string `json:"sub,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	IDToken      string `json:"id_token"`
+}
+
+type oauthErrResponse struct {
+	Error       string `json:"error" example:"invalid_grant"`
+	Description string `json:"error_description,omitempty"`
+}
+
+type loginPageParams struct {
+	Form    authorizeParams
+	Message string
+}
+
+func newOAuthErrResponse(err error) (int, oauthErrResponse) {
+	var detail string
+	if applicationErr := errors.Unwrap(err); applicationErr != nil {
+		detail = applicationErr.Error()
+	}
+
+	switch {
+	case errors.Is(err, services.InternalServerErr):
+		return http.StatusInternalServerError, oauthErrResponse{Error: oauthServerError}
+	case errors.Is(err, services.NoClientRecord), errors.Is(err, services.InvalidClientSecret):
+		return http.StatusUnauthorized, oauthErrResponse{Error: oauthInvalidClient, Description: detail}
+	case errors.Is(err, services.UnauthorizedGrant), errors.Is(err, services.MismatchClient):
+		return http.StatusBadRequest, oauthErrResponse{Error: oauthUnauthorizedClient, Description: detail}
+	case errors.Is(err, services.InvalidScope):
+		return http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidScope, Description: detail}
+	default:
+		return http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidGrant, Description: detail}
+	}
+}
+
+// clientCredential RFC 6749 2.3.1 に従いBasic認証ヘッダ、またはリクエストボディからクライアントの認証情報を取得する
+func clientCredential(c *gin.Context, formID, formSecret string) (string, string) {
+	id, secret, ok := c.Request.BasicAuth()
+	if !ok {
+		return formID, formSecret
+	}
+
+	if decoded, err := url.QueryUnescape(id); err == nil {
+		id = decoded
+	}
+	if decoded, err := url.QueryUnescape(secret); err == nil {
+		secret = decoded
+	}
+	return id, secret
+}
+
+// AuthorizePage ログイン画面を表示する
+// クライアントはこの画面に利用者をリダイレクトするため、パスワードはクライアントに渡らない
+func (h TokenHandler) AuthorizePage(c *gin.Context) {
+	var params authorizeParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: err.Error()},
+		)
+		return
+	}
+
+	// 未登録のクライアントやリダイレクトURIの場合はリダイレクトせずにエラーを返す
+	err := h.authenticateSvc.VerifyAuthorizationRequest(
+		models.NewAuthorizationRequest(params.ClientID, params.RedirectURI, params.Scope, params.CodeChallenge),
+	)
+	if err != nil {
+		status, response := newOAuthErrResponse(err)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	h.renderLoginPage(c, http.StatusOK, loginPageParams{Form: params})
+}
+
+// Authorize issue authorization code
+// @Summary Redirect to client with authorization code (PKCE S256 required)
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Param response_type formData string true "code"
+// @Param client_id formData string true "Client ID"
+// @Param redirect_uri formData string true "Redirect URI"
+// @Param scope formData string false "Scope"
+// @Param state formData string false "State"
+// @Param code_challenge formData string true "BASE64URL(SHA256(code_verifier))"
+// @Param code_challenge_method formData string true "S256"
+// @Param email formData string true "Email"
+// @Param password formData string true "Password"
+// @Success 302
+// @Failure 400 {object} controller.oauthErrResponse
+// @Router  /auth/authorize [post]
+func (h TokenHandler) Authorize(c *gin.Context) {
+	var form authorizeForm
+	if err := c.ShouldBind(&form); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: err.Error()},
+		)
+		return
+	}
+
+	redirectURI, err := url.Parse(form.RedirectURI)
+	if err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: err.Error()},
+		)
+		return
+	}
+
+	code, err := h.authenticateSvc.Authorize(
+		CurrentOrganization(c),
+		form.Email,
+		form.Password,
+		uuid.New().String(),
+		models.NewAuthorizationRequest(form.ClientID, form.RedirectURI, form.Scope, form.CodeChallenge),
+		time.Now(),
+	)
+	if err != nil {
+		// 認証に失敗した場合はクライアントに戻さず、再度ログイン画面を表示する
+		status, response := newErrResponse(err, form.Email)
+		if status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		h.renderLoginPage(c, status, loginPageParams{Form: form.authorizeParams, Message: response.Message})
+		return
+	}
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	if form.State != "" {
+		query.Set("state", form.State)
+	}
+	redirectURI.RawQuery = query.Encode()
+	c.Redirect(http.StatusFound, redirectURI.String())
+}
+
+// Token exchange grant for tokens
+// @Summary Return tokens by authorization_code, refresh_token or client_credentials grant
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Param grant_type formData string true "authorization_code, refresh_token or client_credentials"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI used on authorization request"
+// @Param client_id formData string false "Client ID"
+// @Param client_secret formData string false "Client secret (or use Basic authorization)"
+// @Param scope formData string false "Scope for client_credentials"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Produce json
+// @Success 200 {object} controller.tokenResponse
+// @Failure default {object} controller.oauthErrResponse
+// @Router  /auth/token [post]
+func (h TokenHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var form tokenForm
+	if err := c.ShouldBind(&form); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: err.Error()},
+		)
+		return
+	}
+
+	now := time.Now()
+	clientID, clientSecret := clientCredential(c, form.ClientID, form.ClientSecret)
+	var (
+		token *models.Token
+		err   error
+	)
+	switch form.GrantType {
+	case models.GrantTypeAuthorizationCode:
+		if form.Code == "" || form.RedirectURI == "" || clientID == "" || form.CodeVerifier == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, oauthErrResponse{
+				Error:       oauthInvalidRequest,
+				Description: "code, redirect_uri, client_id, code_verifier は必須です",
+			})
+			return
+		}
+		token, err = h.authenticateSvc.Exchange(
+			CurrentOrganization(c), form.Code, clientID, clientSecret, form.RedirectURI, form.CodeVerifier, uuid.New().String(), now,
+		)
+	case models.GrantTypeClientCredentials:
+		if clientID == "" {
+			c.AbortWithStatusJSON(
+				http.StatusUnauthorized, oauthErrResponse{Error: oauthInvalidClient, Description: "client_id は必須です"},
+			)
+			return
+		}
+		token, err = h.authenticateSvc.ClientCredentials(
+			CurrentOrganization(c), clientID, clientSecret, form.Scope, now,
+		)
+	case models.GrantTypeRefreshToken:
+		if form.RefreshToken == "" {
+			c.AbortWithStatusJSON(
+				http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: "refresh_token は必須です"},
+			)
+			return
+		}
+		token, err = h.authenticateSvc.Refresh(
+			CurrentOrganization(c), clientID, clientSecret, uuid.New().String(), form.RefreshToken, now.UTC(),
+		)
+	default:
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthUnsupportedGrantType, Description: form.GrantType},
+		)
+		return
+	}
+	if err != nil {
+		status, response := newOAuthErrResponse(err)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(*token, now))
+}
+
+// Revoke revoke token
+// @Summary Revoke refresh token or id token (RFC 7009). Unknown tokens also return 200
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Token to revoke"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Param client_id formData string false "Client ID"
+// @Param client_secret formData string false "Client secret (or use Basic authorization)"
+// @Success 200
+// @Failure default {object} controller.oauthErrResponse
+// @Router  /auth/revoke [post]
+func (h TokenHandler) Revoke(c *gin.Context) {
+	var form revokeForm
+	if err := c.ShouldBind(&form); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: err.Error()},
+		)
+		return
+	}
+
+	clientID, clientSecret := clientCredential(c, form.ClientID, form.ClientSecret)
+	err := h.authenticateSvc.Revoke(form.Token, form.TokenTypeHint, clientID, clientSecret, time.Now())
+	if err != nil {
+		status, response := newOAuthErrResponse(err)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// Introspect introspect token
+// @Summary Return whether token is active (RFC 7662). Client authentication is required
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Param token formData string true "Token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Param client_id formData string false "Client ID"
+// @Param client_secret formData string false "Client secret (or use Basic authorization)"
+// @Produce json
+// @Success 200 {object} controller.introspectionResponse
+// @Failure default {object} controller.oauthErrResponse
+// @Router  /auth/introspect [post]
+func (h TokenHandler) Introspect(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	var form introspectForm
+	if err := c.ShouldBind(&form); err != nil {
+		c.AbortWithStatusJSON(
+			http.StatusBadRequest, oauthErrResponse{Error: oauthInvalidRequest, Description: err.Error()},
+		)
+		return
+	}
+
+	clientID, clientSecret := clientCredential(c, form.ClientID, form.ClientSecret)
+	if clientID == "" {
+		c.AbortWithStatusJSON(
+			http.StatusUnauthorized, oauthErrResponse{Error: oauthInvalidClient, Description: "client_id は必須です"},
+		)
+		return
+	}
+
+	result, err := h.authenticateSvc.Introspect(
+		CurrentOrganization(c), form.Token, form.TokenTypeHint, clientID, clientSecret, time.Now(),
+	)
+	if err != nil {
+		status, response := newOAuthErrResponse(err)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	if result == nil {
+		c.JSON(http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+
+	claims := result.Claims()
+	c.JSON(http.StatusOK, introspectionResponse{
+		Active:    true,
+		TokenType: result.TokenType(),
+		Sub:       claims.Subject(),
+		Email:     claims.Email(),
+		Exp:       claims.ExpiredAt().Unix(),
+		Iat:       claims.IssuedAt().Unix(),
+		Scope:     claims.Scope(),
+		ClientID:  claims.ClientID(),
+	})
+}
+
+func (h TokenHandler) renderLoginPage(c *gin.Context, status int, params loginPageParams) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := loginPage.Execute(c.Writer, params); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+func newTokenResponse(token models.Token, now time.Time) tokenResponse {
+	return tokenResponse{
+		AccessToken:  token.IDToken(),
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(token.ExpiredAt().Sub(now).Seconds()),
+		RefreshToken: token.Refresh(),
+		IDToken:      token.IDToken(),
+	}
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewOrganizationHandler(registry services.OrganizationRegistry) OrganizationHandler {
+	return OrganizationHandler{
+		registry: registry,
+	}
+}
+
+type OrganizationHandler struct {
+	registry services.OrganizationRegistry
+}
+
+type inputOrganization struct {
+	Slug string `json:"slug" binding:"required,alphanum,lowercase,max=64" example:"example"`
+	Name string `json:"name" binding:"required"`
+	Host string `json:"host" binding:"omitempty,hostname" example:"example.com"`
+}
+
+type organizationResponse struct {
+	ID   string `json:"id" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	Slug string `json:"slug" example:"example"`
+	Name string `json:"name"`
+	Host string `json:"host,omitempty" example:"example.com"`
+}
+
+// Create register organization
+// @Summary Register an organization. Its users are accessed by /t/{slug}/v1/... or its host
+// @Tags Admin
+// @Param inputOrganization body controller.inputOrganization true "Organization"
+// @Produce json
+// @Success 200 {object} controller.organizationResponse
+// @Failure default {object} controller.errResponse
+// @Router /admin/organizations [post]
+// @Security Bearer
+func (h OrganizationHandler) Create(c *gin.Context) {
+	var input inputOrganization
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	organization, err := h.registry.Register(
+		models.NewOrganization(uuid.New().String(), input.Slug, input.Name, input.Host),
+	)
+	if err != nil {
+		status, response := newErrResponse(err, input.Slug)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, organizationResponse{
+		ID:   organization.ID(),
+		Slug: organization.Slug(),
+		Name: organization.Name(),
+		Host: organization.Host(),
+	})
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"auth-test/services"
+)
+
+func NewPasswordResetHandler(service services.PasswordReset) PasswordResetHandler {
+	return PasswordResetHandler{
+		service: service,
+	}
+}
+
+type PasswordResetHandler struct {
+	service services.PasswordReset
+}
+
+type inputForgotPassword struct {
+	Email string `json:"email" binding:"required,email" example:"test@example.com"`
+}
+
+type inputResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72,nist_sp_800_63" minLength:"8" maxLength:"72" example:"string"`
+}
+
+// Forgot send password reset mail
+// @Summary Send a password reset link to the email address. Always returns 202 whether or not the email address is registered
+// @Tags UserAccount
+// @Param inputForgotPassword body controller.inputForgotPassword true "Email"
+// @Success 202
+// @Failure 400 {object} controller.errResponse
+// @Router /users/password/forgot [post]
+func (h PasswordResetHandler) Forgot(c *gin.Context) {
+	var input inputForgotPassword
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	// 送信の失敗や送信にかかる時間から登録済みのメールアドレスを推測されないよう、
+	// レスポンスを返した後に送信し、失敗はログにのみ出力する
+	orgID := CurrentOrganization(c)
+	go func() {
+		if err := h.service.Forgot(orgID, input.Email, time.Now().UTC()); err != nil {
+			log.Printf("パスワード再設定メールの送信に失敗。: %s: %v \n", input.Email, rootCause(err))
+		}
+	}()
+
+	c.Status(http.StatusAccepted)
+}
+
+// Reset set new password with reset token
+// @Summary Set a new password with the token sent in the password reset mail. All sessions and tokens of the user are revoked
+// @Tags UserAccount
+// @Param inputResetPassword body controller.inputResetPassword true "Token in the reset link and new password"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /users/password/reset [post]
+func (h PasswordResetHandler) Reset(c *gin.Context) {
+	var input inputResetPassword
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if validationErrs, ok := err.(validator.ValidationErrors); ok && validationErrs[0].Field() == "Password" {
+			bodyErr := newAccountBodyError(validationErrs[0])
+			c.AbortWithStatusJSON(http.StatusBadRequest, bodyErr.getResponse())
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	if err := h.service.Reset(input.Token, input.Password, time.Now().UTC()); err != nil {
+		status, response := newErrResponse(err, "")
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
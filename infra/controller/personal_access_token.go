@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewPersonalAccessTokenHandler(service services.PersonalAccessTokens) PersonalAccessTokenHandler {
+	return PersonalAccessTokenHandler{
+		service: service,
+	}
+}
+
+type PersonalAccessTokenHandler struct {
+	service services.PersonalAccessTokens
+}
+
+type inputPersonalAccessToken struct {
+	Name      string `json:"name" binding:"required,max=255" example:"ci"`
+	Scope     string `json:"scope" example:"user:read"`
+	ExpiresIn int64  `json:"expires_in" binding:"required,min=1" example:"2592000"`
+}
+
+type personalAccessTokenPathParams struct {
+	ID string `uri:"token_id" binding:"required,uuid" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+}
+
+type personalAccessTokenResponse struct {
+	ID        string    `json:"id" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	Token     string    `json:"token,omitempty" example:"pat_xxxxxxxx"`
+	Name      string    `json:"name" example:"ci"`
+	Scope     string    `json:"scope,omitempty" example:"user:read"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+// Create issue personal access token
+// @Summary Issue a personal access token. The token is shown only in this response
+// @Tags PersonalAccessToken
+// @Param inputPersonalAccessToken body controller.inputPersonalAccessToken true "Name, scope and lifetime in seconds"
+// @Produce json
+// @Success 200 {object} controller.personalAccessTokenResponse
+// @Failure default {object} controller.errResponse
+// @Router /auth/tokens [post]
+// @Security Bearer
+func (h PersonalAccessTokenHandler) Create(c *gin.Context) {
+	principal, ok := CurrentPrincipal(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var input inputPersonalAccessToken
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	token, value, err := h.service.Issue(
+		principal, input.Name, input.Scope, time.Duration(input.ExpiresIn)*time.Second, time.Now().UTC(),
+	)
+	if err != nil {
+		status, response := newErrResponse(err, input.Name)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	response := newPersonalAccessTokenResponse(*token)
+	response.Token = value
+	c.JSON(http.StatusOK, response)
+}
+
+// List get personal access tokens
+// @Summary Return active personal access tokens of the token owner
+// @Tags PersonalAccessToken
+// @Produce json
+// @Success 200 {object} []controller.personalAccessTokenResponse
+// @Failure default {object} controller.errResponse
+// @Router /auth/tokens [get]
+// @Security Bearer
+func (h PersonalAccessTokenHandler) List(c *gin.Context) {
+	principal, ok := CurrentPrincipal(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.service.List(principal.Subject(), time.Now().UTC())
+	if err != nil {
+		status, response := newErrResponse(err, "")
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	response := make([]personalAccessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, newPersonalAccessTokenResponse(token))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Revoke revoke personal access token
+// @Summary Revoke a personal access token of the token owner
+// @Tags PersonalAccessToken
+// @Param token_id path string true "Token ID by UUID"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /auth/tokens/{token_id} [delete]
+// @Security Bearer
+func (h PersonalAccessTokenHandler) Revoke(c *gin.Context) {
+	principal, ok := CurrentPrincipal(c)
+	if !ok {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var params personalAccessTokenPathParams
+	if err := c.ShouldBindUri(&params); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	if err := h.service.Revoke(principal.Subject(), params.ID, time.Now().UTC()); err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+func newPersonalAccessTokenResponse(token models.PersonalAccessToken) personalAccessTokenResponse {
+	return personalAccessTokenResponse{
+		ID:        token.ID(),
+		Name:      token.Name(),
+		Scope:     token.Scope(),
+		IssuedAt:  token.IssuedAt(),
+		ExpiredAt: token.ExpiredAt(),
+	}
+}
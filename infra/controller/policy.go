@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewPolicyHandler(policy models.PolicyEvaluator) PolicyHandler {
+	return PolicyHandler{policy: policy}
+}
+
+type PolicyHandler struct {
+	policy models.PolicyEvaluator
+}
+
+// Require ルートの操作と操作対象を宣言し、認証済みの主体に許可されているかポリシーで評価する
+// パスにユーザIDを含むルートではそのユーザを操作対象の持ち主とする
+func (h PolicyHandler) Require(action, resource string) gin.HandlerFunc {
+	return h.require(action, resource, func(c *gin.Context, _ models.Principal) string { return c.Param("id") })
+}
+
+// RequireSelf 主体自身に属する操作対象を扱うルートで、主体を操作対象の持ち主として評価する
+func (h PolicyHandler) RequireSelf(action, resource string) gin.HandlerFunc {
+	return h.require(action, resource, func(_ *gin.Context, p models.Principal) string { return p.Subject() })
+}
+
+func (h PolicyHandler) require(
+	action, resource string, owner func(*gin.Context, models.Principal) string,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(
+				http.StatusUnauthorized,
+				errResponse{Message: services.FailedCheckOwner.Error(), Detail: "認証されていません"},
+			)
+			return
+		}
+
+		// クライアントに発行したトークンとパーソナルアクセストークンは、ポリシーに加えてスコープでも制限する
+		request := models.NewPolicyRequest(principal, action, resource, owner(c, principal))
+		if !principal.Permits(action, resource) || !h.policy.Evaluate(request) {
+			status, response := newErrResponse(
+				services.NewApplicationErr(
+					services.FailedCheckOwner,
+					services.NewApplicationErr(services.PermissionDenied, fmt.Errorf("%s %s", action, resource)),
+				),
+				"",
+			)
+			c.AbortWithStatusJSON(status, response)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,171 @@
+package controller_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"auth-test/infra/auth"
+	"auth-test/infra/controller"
+	"auth-test/infra/memory"
+	"auth-test/infra/policy"
+	"auth-test/models"
+	"auth-test/services"
+)
+
+const (
+	testEmail       = "user@example.com"
+	testPassword    = "Passw0rd!long"
+	testRedirectURI = "https://client.example.com/callback"
+	testClientID    = "client"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// newTokenAuthorization 利用者1人と、openidスコープのみ許可した公開クライアントを登録したメモリのストアで初期化する
+func newTokenAuthorization(t *testing.T) (services.TokenAuthorization, string) {
+	t.Helper()
+	store := memory.NewStore()
+	accounts := memory.NewUserAccountRepository(store)
+	clients := memory.NewClientRepository(store)
+
+	account, err := accounts.Insert(models.DefaultOrganizationID, uuid.New().String(), testEmail, "name", testPassword)
+	if err != nil {
+		t.Fatalf("ユーザの登録に失敗: %v", err)
+	}
+	client := models.NewClient(
+		testClientID, testClientID, "", []string{testRedirectURI},
+		[]string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken}, []string{"openid"},
+	)
+	if _, err = clients.Insert(client); err != nil {
+		t.Fatalf("クライアントの登録に失敗: %v", err)
+	}
+
+	authorizer, err := auth.NewAuthorizer(
+		"http://localhost:8080", auth.AlgorithmHS256, "secret", "", time.Hour, memory.NewSigningKeyRepository(store),
+	)
+	if err != nil {
+		t.Fatalf("署名鍵の生成に失敗: %v", err)
+	}
+	limit, err := services.NewSessionLimit(0, services.LimitPolicyReject, memory.NewLocker())
+	if err != nil {
+		t.Fatalf("同時ログイン数の制限の生成に失敗: %v", err)
+	}
+
+	service := services.NewTokenAuthorization(
+		authorizer,
+		memory.NewTokenRepository(store),
+		memory.NewRevokedTokenRepository(store),
+		memory.NewAuthorizationCodeRepository(store),
+		clients,
+		accounts,
+		memory.NewRoleRepository(store),
+		memory.NewPersonalAccessTokenRepository(store),
+		memory.NewSecurityEventRepository(store),
+		limit,
+		services.NewSignInPolicy(false),
+		time.Hour,
+		10*time.Minute,
+		time.Minute,
+	)
+	return service, account.ID()
+}
+
+// issueClientToken 利用者がクライアントを認可し、認可コードと交換したIDトークンを返す
+func issueClientToken(t *testing.T, service services.TokenAuthorization, now time.Time) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(testVerifier))
+	request := models.NewAuthorizationRequest(
+		testClientID, testRedirectURI, "openid", base64.RawURLEncoding.EncodeToString(sum[:]),
+	)
+	code, err := service.Authorize(
+		models.DefaultOrganizationID, testEmail, testPassword, uuid.New().String(), request, now,
+	)
+	if err != nil {
+		t.Fatalf("認可コードの発行に失敗: %v", err)
+	}
+
+	token, err := service.Exchange(
+		models.DefaultOrganizationID, code, testClientID, "", testRedirectURI, testVerifier, uuid.New().String(), now,
+	)
+	if err != nil {
+		t.Fatalf("認可コードの交換に失敗: %v", err)
+	}
+	return token.IDToken()
+}
+
+func TestRequireRestrictsClientToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, userID := newTokenAuthorization(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	engine, err := policy.NewEngine("")
+	if err != nil {
+		t.Fatalf("ポリシーの初期化に失敗: %v", err)
+	}
+	policies := controller.NewPolicyHandler(engine)
+	tokens := controller.NewTokenHandler(service)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router := gin.New()
+	router.PUT("/users/:id", tokens.VerifyIDToken, policies.Require(models.ActionUpdate, models.ResourceUser), ok)
+	router.DELETE("/users/:id", tokens.VerifyIDToken, policies.Require(models.ActionDelete, models.ResourceUser), ok)
+	router.POST("/tokens", tokens.VerifyIDToken, policies.RequireSelf(models.ActionCreate, models.ResourcePAT), ok)
+
+	clientToken := issueClientToken(t, service, now)
+	claimed, err := service.Claim(models.DefaultOrganizationID, testEmail, testPassword, uuid.New().String(), now)
+	if err != nil {
+		t.Fatalf("トークンの発行に失敗: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{
+			name: "クライアントのトークンでユーザを更新", token: clientToken, method: http.MethodPut,
+			path: "/users/" + userID, want: http.StatusForbidden,
+		},
+		{
+			name: "クライアントのトークンでユーザを削除", token: clientToken, method: http.MethodDelete,
+			path: "/users/" + userID, want: http.StatusForbidden,
+		},
+		{
+			name: "クライアントのトークンでパーソナルアクセストークンを発行", token: clientToken, method: http.MethodPost,
+			path: "/tokens", want: http.StatusForbidden,
+		},
+		{
+			name: "Claimのトークンでユーザを更新", token: claimed.IDToken(), method: http.MethodPut,
+			path: "/users/" + userID, want: http.StatusOK,
+		},
+		{
+			name: "Claimのトークンでユーザを削除", token: claimed.IDToken(), method: http.MethodDelete,
+			path: "/users/" + userID, want: http.StatusOK,
+		},
+		{
+			name: "Claimのトークンでパーソナルアクセストークンを発行", token: claimed.IDToken(), method: http.MethodPost,
+			path: "/tokens", want: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			request := httptest.NewRequest(tt.method, tt.path, nil)
+			request.Header.Set("Authorization", "Bearer "+tt.token)
+			router.ServeHTTP(w, request)
+
+			if w.Code != tt.want {
+				t.Fatalf("ステータスが一致しません: want %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
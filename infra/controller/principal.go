@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// principalKey 認証済みの主体をgin.Contextに格納するキー
+const principalKey = "principal"
+
+func setPrincipal(c *gin.Context, principal models.Principal) {
+	c.Set(principalKey, principal)
+}
+
+// CurrentPrincipal 認証ミドルウェアが格納した主体を取得する
+func CurrentPrincipal(c *gin.Context) (models.Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return models.Principal{}, false
+	}
+
+	principal, ok := v.(models.Principal)
+	return principal, ok
+}
+
+// RequireRole 主体が指定したロールのいずれかを持つことを確認する
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := CurrentPrincipal(c)
+		if !ok {
+			c.AbortWithStatusJSON(
+				http.StatusUnauthorized,
+				errResponse{Message: services.FailedCheckRole.Error(), Detail: "認証されていません"},
+			)
+			return
+		}
+
+		for _, role := range roles {
+			if principal.HasRole(role) {
+				c.Next()
+				return
+			}
+		}
+
+		status, response := newErrResponse(
+			services.NewApplicationErr(
+				services.FailedCheckRole,
+				services.NewApplicationErr(services.PermissionDenied, errors.New(strings.Join(roles, ","))),
+			),
+			"",
+		)
+		c.AbortWithStatusJSON(status, response)
+	}
+}
@@ -31,6 +31,13 @@ func newErrResponse(err error, detail string) (status int, responseErr errRespon
 		status = http.StatusInternalServerError
 	case errors.Is(applicationErr, services.NoSessionRecord):
 		status = http.StatusUnauthorized
+	case errors.Is(applicationErr, services.PermissionDenied), errors.Is(applicationErr, services.DisabledAccount),
+		errors.Is(applicationErr, services.InvalidCSRFToken), errors.Is(applicationErr, services.TooManySessions):
+		status = http.StatusForbidden
+	case errors.Is(applicationErr, services.NoTenantRecord), errors.Is(applicationErr, services.UnknownSession):
+		status = http.StatusNotFound
+	case errors.Is(applicationErr, services.LockTimeout):
+		status = http.StatusServiceUnavailable
 	default:
 		status = http.StatusBadRequest
 	}
@@ -45,3 +52,14 @@ func newErrResponse(err error, detail string) (status int, responseErr errRespon
 		Detail:  fmt.Sprintf("%s%s", errors.Unwrap(err).Error(), detailMsg),
 	}
 }
+
+// rootCause ログに出力するため、ApplicationErrに包まれた最初のエラーを取り出す
+func rootCause(err error) error {
+	for {
+		cause := errors.Unwrap(err)
+		if cause == nil {
+			return err
+		}
+		err = cause
+	}
+}
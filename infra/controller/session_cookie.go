@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFHeader Cookieで認証するリクエストがCSRFトークンを送信するヘッダ
+const CSRFHeader = "X-CSRF-Token"
+
+// NewSessionCookie secretはCSRFトークンの導出に利用する
+func NewSessionCookie(enabled bool, name, domain, path, secret string) SessionCookie {
+	return SessionCookie{
+		enabled: enabled,
+		name:    name,
+		domain:  domain,
+		path:    path,
+		secret:  []byte(secret),
+	}
+}
+
+// SessionCookie セッショントークンをHttpOnlyのCookieで受け渡す設定
+// CSRFトークンはセッショントークンのHMACとし、Cookieとレスポンスで渡した値をヘッダで送り返させる(ダブルサブミット)
+type SessionCookie struct {
+	enabled bool
+	name    string
+	domain  string
+	path    string
+	secret  []byte
+}
+
+func (s SessionCookie) csrfName() string { return s.name + "_csrf" }
+
+// csrfToken セッションに紐づけることで、別のセッションのCSRFトークンを流用できないようにする
+func (s SessionCookie) csrfToken(session string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(session))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s SessionCookie) write(c *gin.Context, session string) string {
+	csrf := s.csrfToken(session)
+	s.set(c, s.name, session, true, 0)
+	// CSRFトークンはスクリプトから読み取ってヘッダに付与できるようにHttpOnlyにしない
+	s.set(c, s.csrfName(), csrf, false, 0)
+	return csrf
+}
+
+func (s SessionCookie) clear(c *gin.Context) {
+	s.set(c, s.name, "", true, -1)
+	s.set(c, s.csrfName(), "", false, -1)
+}
+
+func (s SessionCookie) set(c *gin.Context, name, value string, httpOnly bool, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Domain:   s.domain,
+		Path:     s.path,
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: httpOnly,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// read Cookieモードでない場合は常に見つからない
+func (s SessionCookie) read(c *gin.Context) (string, bool) {
+	if !s.enabled {
+		return "", false
+	}
+
+	session, err := c.Cookie(s.name)
+	if err != nil || session == "" {
+		return "", false
+	}
+	return session, true
+}
+
+// verifyCSRF 状態を変更しないメソッドは検証しない
+func (s SessionCookie) verifyCSRF(c *gin.Context, session string) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	header := c.GetHeader(CSRFHeader)
+	if header == "" {
+		return false
+	}
+	return hmac.Equal([]byte(header), []byte(s.csrfToken(session)))
+}
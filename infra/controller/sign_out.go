@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+
+	"auth-test/services"
+)
+
+func NewSignOutHandler(service services.SignOut, cookie SessionCookie) SignOutHandler {
+	return SignOutHandler{
+		service: service,
+		cookie:  cookie,
+	}
+}
+
+// SignOutHandler セッションとIDトークンの両方のルートから利用する
+type SignOutHandler struct {
+	service services.SignOut
+	cookie  SessionCookie
+}
+
+// SignOutEverywhere revoke all credentials of user
+// @Summary Delete all sessions and refresh tokens of a user and invalidate issued ID tokens and personal access tokens, including the ones used for this request
+// @Tags Logout
+// @Param id path string true "User ID by UUID"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /auth/users/{id}/signout [post]
+// @Security Bearer
+func (h SignOutHandler) SignOutEverywhere(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	if err := h.service.SignOutEverywhere(CurrentOrganization(c), params.ID, time.Now().UTC()); err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+	if h.cookie.enabled {
+		h.cookie.clear(c)
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// TenantPathPrefix /t/{slug}/v1/... の形式でテナントを指定する
+const TenantPathPrefix = "/t/"
+
+// organizationKey 特定した組織をgin.Contextに格納するキー
+const organizationKey = "organization"
+
+// tenantSlugKey パスから取り除いたテナントを、ルーティングの後も参照するためのキー
+type tenantSlugKey struct{}
+
+func NewTenantHandler(registry services.OrganizationRegistry) TenantHandler {
+	return TenantHandler{registry: registry}
+}
+
+type TenantHandler struct {
+	registry services.OrganizationRegistry
+}
+
+// StripTenantPrefix テナントのプレフィックスを取り除いてからルーティングする
+// ルーティングより前に書き換える必要があるため、gin.Engineをhttp.Handlerとして包む
+func StripTenantPrefix(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slug, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, TenantPathPrefix), "/")
+		if !strings.HasPrefix(r.URL.Path, TenantPathPrefix) || !ok || slug == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = r.Clone(context.WithValue(r.Context(), tenantSlugKey{}, slug))
+		r.URL.Path = "/" + path
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Resolve パスのプレフィックスまたはHostヘッダから組織を特定する
+func (h TenantHandler) Resolve(c *gin.Context) {
+	slug, _ := c.Request.Context().Value(tenantSlugKey{}).(string)
+	host, _, err := net.SplitHostPort(c.Request.Host)
+	if err != nil {
+		host = c.Request.Host
+	}
+
+	organization, err := h.registry.Resolve(slug, host)
+	if err != nil {
+		status, response := newErrResponse(err, slug)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Set(organizationKey, *organization)
+	c.Next()
+}
+
+// CurrentOrganization リクエストの組織のIDを取得する
+func CurrentOrganization(c *gin.Context) string {
+	v, ok := c.Get(organizationKey)
+	if !ok {
+		return models.DefaultOrganizationID
+	}
+
+	organization, ok := v.(models.Organization)
+	if !ok {
+		return models.DefaultOrganizationID
+	}
+	return organization.ID()
+}
+
+// RequireDefaultOrganization 組織をまたぐ操作は既定の組織へのリクエストに限る
+func RequireDefaultOrganization(c *gin.Context) {
+	if CurrentOrganization(c) != models.DefaultOrganizationID {
+		c.AbortWithStatusJSON(
+			http.StatusForbidden,
+			errResponse{Message: services.PermissionDenied.Error(), Detail: "既定の組織からのみ操作できます"},
+		)
+		return
+	}
+
+	c.Next()
+}
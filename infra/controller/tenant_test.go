@@ -0,0 +1,104 @@
+package controller_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"auth-test/infra/controller"
+	"auth-test/infra/memory"
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func TestStripTenantPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	organizations := memory.NewOrganizationRepository(memory.NewStore())
+	acme, err := organizations.Insert(models.NewOrganization(uuid.New().String(), "acme", "acme", ""))
+	if err != nil {
+		t.Fatalf("組織の登録に失敗: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantStatus   int
+		wantOrg      string
+		wantHandlers int
+	}{
+		{
+			name: "プレフィックスの無いパス", method: http.MethodGet, path: "/v1/users/abc",
+			wantStatus: http.StatusOK, wantOrg: models.DefaultOrganizationID, wantHandlers: 1,
+		},
+		{
+			name: "プレフィックスのあるGET", method: http.MethodGet, path: "/t/acme/v1/users/abc",
+			wantStatus: http.StatusOK, wantOrg: acme.ID(), wantHandlers: 1,
+		},
+		{
+			name: "プレフィックスのあるPUTは本文を1回だけ読む", method: http.MethodPut, path: "/t/acme/v1/users/abc",
+			wantStatus: http.StatusOK, wantOrg: acme.ID(), wantHandlers: 1,
+		},
+		{
+			name: "プレフィックスのあるPOST", method: http.MethodPost, path: "/t/acme/v1/users/abc",
+			wantStatus: http.StatusOK, wantOrg: acme.ID(), wantHandlers: 1,
+		},
+		{
+			name: "存在しない組織", method: http.MethodGet, path: "/t/unknown/v1/users/abc",
+			wantStatus: http.StatusNotFound, wantHandlers: 0,
+		},
+		{
+			name: "一致するルートが無いパス", method: http.MethodGet, path: "/t/acme/nothing",
+			wantStatus: http.StatusNotFound, wantHandlers: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				calls int
+				org   string
+			)
+			handle := func(c *gin.Context) {
+				calls++
+				org = controller.CurrentOrganization(c)
+				body, err := io.ReadAll(c.Request.Body)
+				if err != nil || string(body) != "{}" {
+					c.AbortWithStatus(http.StatusBadRequest)
+					return
+				}
+				c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+			}
+			router := gin.New()
+			router.Use(controller.NewTenantHandler(services.NewOrganizationRegistry(organizations)).Resolve)
+			router.GET("/v1/users/:id", handle)
+			router.PUT("/v1/users/:id", handle)
+			router.POST("/v1/users/:id", handle)
+
+			w := httptest.NewRecorder()
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			controller.StripTenantPrefix(router).ServeHTTP(w, request)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("ステータスが一致しません: want %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if calls != tt.wantHandlers {
+				t.Fatalf("ハンドラの呼び出し回数が一致しません: want %d, got %d", tt.wantHandlers, calls)
+			}
+			if tt.wantHandlers == 0 {
+				return
+			}
+			if org != tt.wantOrg {
+				t.Fatalf("組織が一致しません: want %s, got %s", tt.wantOrg, org)
+			}
+			if w.Body.String() != `{"id":"abc"}` {
+				t.Fatalf("レスポンスが一致しません: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package controller
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -11,15 +13,19 @@ import (
 	"auth-test/services"
 )
 
-func NewUserAccountHandler(svc services.UserAccount, validate validator.Validate) UserAccountHandler {
+func NewUserAccountHandler(
+	svc services.UserAccount, verifier services.EmailVerifier, validate validator.Validate,
+) UserAccountHandler {
 	return UserAccountHandler{
 		service:  svc,
+		verifier: verifier,
 		validate: validate,
 	}
 }
 
 type UserAccountHandler struct {
 	service  services.UserAccount
+	verifier services.EmailVerifier
 	validate validator.Validate
 }
 
@@ -33,6 +39,19 @@ type inputUserAccount struct {
 	Password string `json:"password" binding:"required,min=8,max=72,nist_sp_800_63" minLength:"8" maxLength:"72" example:"string"`
 }
 
+// inputUserProfile メールアドレスを変更する場合はcurrent_passwordが必要
+type inputUserProfile struct {
+	Email           string `json:"email" binding:"required,email" example:"test@example.com"`
+	Name            string `json:"name" binding:"required"`
+	CurrentPassword string `json:"current_password" example:"string"`
+}
+
+type inputChangePassword struct {
+	CurrentPassword     string `json:"current_password" binding:"required" example:"string"`
+	NewPassword         string `json:"new_password" binding:"required,min=8,max=72,nist_sp_800_63" minLength:"8" maxLength:"72" example:"string"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions" example:"true"`
+}
+
 type userAccountResponse struct {
 	ID    string `json:"id" binding:"required,uuid" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
 	Email string `json:"email" binding:"required,email" example:"test@example.com"`
@@ -57,7 +76,7 @@ func (h UserAccountHandler) Get(c *gin.Context) {
 		return
 	}
 
-	userAccount, err := h.service.Find(params.ID)
+	userAccount, err := h.service.Find(CurrentOrganization(c), params.ID)
 	if err != nil {
 		status, response := newErrResponse(err, params.ID)
 		c.AbortWithStatusJSON(status, response)
@@ -74,8 +93,9 @@ func (h UserAccountHandler) Get(c *gin.Context) {
 // @Success 200 {object} []controller.userAccountResponse "空配列の場合nullになってしまうので注意"
 // @Failure default {object} controller.errResponse
 // @Router /users [get]
+// @Security Bearer
 func (h UserAccountHandler) List(c *gin.Context) {
-	accounts, err := h.service.List()
+	accounts, err := h.service.List(CurrentOrganization(c))
 	if err != nil {
 		status, response := newErrResponse(err, "")
 		c.AbortWithStatusJSON(status, response)
@@ -109,7 +129,9 @@ func (h *UserAccountHandler) Create(c *gin.Context) {
 	}
 
 	result, err := h.service.Create(
-		models.NewUserAccount(uuid.New().String(), account.Email, account.Name, account.Password),
+		models.NewUserAccount(
+			CurrentOrganization(c), uuid.New().String(), account.Email, account.Name, account.Password,
+		),
 	)
 	if err != nil {
 		status, response := newErrResponse(err, account.Email)
@@ -117,15 +139,20 @@ func (h *UserAccountHandler) Create(c *gin.Context) {
 		return
 	}
 
+	// 登録は完了しているため、送信に失敗しても確認メールの再送で確認できるようにする
+	if err = h.verifier.Send(*result, time.Now().UTC()); err != nil {
+		log.Printf("確認メールの送信に失敗。: %s: %v \n", result.Email(), rootCause(err))
+	}
+
 	c.JSON(http.StatusOK, userAccountResponse{ID: result.ID(), Email: result.Email(), Name: result.Name()})
 }
 
 // Update is update user accounts
-// @Summary Update a user account
+// @Summary Update the email address and name of a user account. current_password is required to change the email address. Use PUT /auth/users/{id}/password to change the password
 // @Tags UserAccount
 // @securityDefinitions.apiKey ApiKeyAuth
 // @Param id path string true "user id"
-// @Param inputUserAccount body controller.inputUserAccount true "Email, Password and UserName"
+// @Param inputUserProfile body controller.inputUserProfile true "Email, UserName and current password"
 // @Produce json
 // @Success 200 {object} controller.userAccountResponse
 // @Failure default {object} controller.errResponse
@@ -138,7 +165,7 @@ func (h *UserAccountHandler) Update(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
 		return
 	}
-	var account inputUserAccount
+	var account inputUserProfile
 	if err := c.BindJSON(&account); err != nil {
 		accountBodyParam := newAccountBodyError(err.(validator.ValidationErrors)[0])
 		c.AbortWithStatusJSON(http.StatusBadRequest, accountBodyParam.getResponse())
@@ -146,7 +173,8 @@ func (h *UserAccountHandler) Update(c *gin.Context) {
 	}
 
 	result, err := h.service.Update(
-		models.NewUserAccount(params.ID, account.Email, account.Name, account.Password),
+		models.NewUserAccount(CurrentOrganization(c), params.ID, account.Email, account.Name, ""),
+		account.CurrentPassword,
 	)
 	if err != nil {
 		status, response := newErrResponse(err, account.Email)
@@ -157,6 +185,48 @@ func (h *UserAccountHandler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, userAccountResponse{ID: result.ID(), Email: result.Email(), Name: result.Name()})
 }
 
+// ChangePassword is change password of user accounts
+// @Summary Change the password of a user account after verifying the current password. Other sessions, refresh tokens, ID tokens and personal access tokens are revoked when revoke_other_sessions is true
+// @Tags UserAccount
+// @securityDefinitions.apiKey ApiKeyAuth
+// @Param id path string true "User ID by UUID"
+// @Param inputChangePassword body controller.inputChangePassword true "Current and new password"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /auth/users/{id}/password [put]
+// @Security Bearer
+func (h UserAccountHandler) ChangePassword(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+	var input inputChangePassword
+	if err := c.ShouldBindJSON(&input); err != nil {
+		if validationErrs, ok := err.(validator.ValidationErrors); ok && validationErrs[0].Field() == "NewPassword" {
+			bodyErr := newAccountBodyError(validationErrs[0])
+			c.AbortWithStatusJSON(http.StatusBadRequest, bodyErr.getResponse())
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, newValidationErr(invalidRequestBody, err.Error()))
+		return
+	}
+
+	// トークンで認証した場合はセッションを持たないため、全てのセッションが失効の対象になる
+	err := h.service.ChangePassword(
+		CurrentOrganization(c), params.ID, input.CurrentPassword, input.NewPassword,
+		c.GetString(sessionKey), input.RevokeOtherSessions, time.Now().UTC(),
+	)
+	if err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
 // Delete is deletion user accounts
 // @Summary Delete a user account
 // @Tags UserAccount
@@ -173,7 +243,7 @@ func (h UserAccountHandler) Delete(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
 		return
 	}
-	err := h.service.Delete(params.ID)
+	err := h.service.Delete(CurrentOrganization(c), params.ID)
 	if err != nil {
 		status, response := newErrResponse(err, params.ID)
 		c.AbortWithStatusJSON(status, response)
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -9,12 +10,17 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 
+	"auth-test/models"
 	"auth-test/services"
 )
 
-func NewSessionAuth(service services.UserSession) UserSessionHandler {
+// sessionKey 認証に利用したセッショントークンをgin.Contextに格納するキー
+const sessionKey = "session"
+
+func NewSessionAuth(service services.UserSession, cookie SessionCookie) UserSessionHandler {
 	return UserSessionHandler{
 		session: service,
+		cookie:  cookie,
 	}
 }
 
@@ -25,14 +31,33 @@ type loginForm struct {
 
 type UserSessionHandler struct {
 	session services.UserSession
+	cookie  SessionCookie
 }
 
+// SessionToken Cookieモードではセッショントークンの代わりにCSRFトークンを返す
 type SessionToken struct {
-	Value string `json:"value" binding:"required,uuid" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	Value     string `json:"value,omitempty" binding:"required,uuid" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	CSRFToken string `json:"csrf_token,omitempty" example:"b2Nj..."`
+}
+
+type sessionPathParams struct {
+	ID        string `uri:"id" binding:"required,uuid" example:"12345678-89ab-cdef-ghij-klmopqrstuvw"`
+	SessionID string `uri:"sid" binding:"required,hexadecimal,len=16" example:"0123456789abcdef"`
+}
+
+// sessionResponse idはセッショントークンのフィンガープリント
+type sessionResponse struct {
+	ID         string    `json:"id" example:"0123456789abcdef"`
+	Current    bool      `json:"current"`
+	IPAddress  string    `json:"ip_address,omitempty" example:"192.0.2.1"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
 }
 
 // Login get session token
-// @Summary Return session token for login user
+// @Summary Return session token for login user. In cookie mode the token is set as an HttpOnly cookie and a CSRF token is returned
 // @Tags Login
 // @Param loginFrom body controller.loginForm true "Email and Password"
 // @Produce json
@@ -48,21 +73,36 @@ func (a UserSessionHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := a.session.Sign(form.Email, form.Password, uuid.New().String(), time.Now())
+	token, err := a.session.Sign(
+		CurrentOrganization(c), form.Email, form.Password, uuid.New().String(),
+		c.ClientIP(), c.Request.UserAgent(), time.Now(),
+	)
 	if err != nil {
 		status, response := newErrResponse(err, form.Email)
 		c.AbortWithStatusJSON(status, response)
 		return
 	}
 
+	if a.cookie.enabled {
+		c.JSON(http.StatusOK, SessionToken{CSRFToken: a.cookie.write(c, token)})
+		return
+	}
+
 	c.JSON(http.StatusOK, SessionToken{Value: token})
 	return
 }
 
-func (a UserSessionHandler) CheckAuthenticatedOwner(c *gin.Context) {
-	t := c.GetHeader("Authorization")
+// sessionToken Authorizationヘッダを優先し、無い場合はCookieから取得する
+func (a UserSessionHandler) sessionToken(c *gin.Context) (token string, fromCookie bool) {
+	token = strings.Replace(c.GetHeader("Authorization"), "Bearer ", "", 1)
+	if token != "" {
+		return token, false
+	}
+	return a.cookie.read(c)
+}
 
-	token := strings.Replace(t, "Bearer ", "", 1)
+func (a UserSessionHandler) CheckAuthenticated(c *gin.Context) {
+	token, fromCookie := a.sessionToken(c)
 	if "" == token {
 		c.AbortWithStatusJSON(
 			http.StatusUnauthorized,
@@ -70,7 +110,40 @@ func (a UserSessionHandler) CheckAuthenticatedOwner(c *gin.Context) {
 		)
 		return
 	}
+	// Cookieはブラウザが自動で送信するため、状態を変更するリクエストではCSRFトークンを確認する
+	if fromCookie && !a.cookie.verifyCSRF(c, token) {
+		status, response := newErrResponse(
+			services.NewApplicationErr(
+				services.FailedCheckLogin, services.NewApplicationErr(services.InvalidCSRFToken, errors.New(CSRFHeader)),
+			),
+			"",
+		)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	principal, err := a.session.Authenticate(CurrentOrganization(c), token, time.Now())
+	if err != nil {
+		status, response := newErrResponse(err, token)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
 
+	setPrincipal(c, *principal)
+	c.Set(sessionKey, token)
+	c.Next()
+}
+
+// ListSessions get sessions of user
+// @Summary Return active sessions of a user. Session tokens are shown as fingerprints
+// @Tags Session
+// @Param id path string true "User ID by UUID"
+// @Produce json
+// @Success 200 {object} []controller.sessionResponse
+// @Failure default {object} controller.errResponse
+// @Router /session/users/{id}/sessions [get]
+// @Security Bearer
+func (a UserSessionHandler) ListSessions(c *gin.Context) {
 	var params userPathParams
 	if err := c.BindUri(&params); err != nil {
 		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
@@ -78,14 +151,78 @@ func (a UserSessionHandler) CheckAuthenticatedOwner(c *gin.Context) {
 		return
 	}
 
-	err := a.session.FindOwner(params.ID, token)
+	sessions, err := a.session.Sessions(CurrentOrganization(c), params.ID)
 	if err != nil {
-		status, response := newErrResponse(err, token)
+		status, response := newErrResponse(err, params.ID)
 		c.AbortWithStatusJSON(status, response)
 		return
 	}
 
-	c.Next()
+	current := c.GetString(sessionKey)
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, sess := range sessions {
+		response = append(response, sessionResponse{
+			ID:         models.Fingerprint(sess.Token()),
+			Current:    sess.Token() == current,
+			IPAddress:  sess.IPAddress(),
+			UserAgent:  sess.UserAgent(),
+			CreatedAt:  sess.CreatedAt(),
+			LastSeenAt: sess.LastSeenAt(),
+			ExpiredAt:  sess.ExpiredAt(),
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeSession delete session of user
+// @Summary Delete a session of a user by its fingerprint
+// @Tags Session
+// @Param id path string true "User ID by UUID"
+// @Param sid path string true "Session fingerprint"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /session/users/{id}/sessions/{sid} [delete]
+// @Security Bearer
+func (a UserSessionHandler) RevokeSession(c *gin.Context) {
+	var params sessionPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	if err := a.session.Revoke(CurrentOrganization(c), params.ID, params.SessionID); err != nil {
+		status, response := newErrResponse(err, params.SessionID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// SignOutOthers delete sessions of user except current one
+// @Summary Delete all sessions of a user except the session used for this request
+// @Tags Session
+// @Param id path string true "User ID by UUID"
+// @Success 200
+// @Failure default {object} controller.errResponse
+// @Router /session/users/{id}/sessions [delete]
+// @Security Bearer
+func (a UserSessionHandler) SignOutOthers(c *gin.Context) {
+	var params userPathParams
+	if err := c.BindUri(&params); err != nil {
+		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
+		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		return
+	}
+
+	if err := a.session.SignOutOthers(CurrentOrganization(c), params.ID, c.GetString(sessionKey)); err != nil {
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
+		return
+	}
+
+	c.Status(http.StatusOK)
 }
 
 // Logout delete session token
@@ -101,8 +238,7 @@ func (a UserSessionHandler) CheckAuthenticatedOwner(c *gin.Context) {
 // @Router  /session/logout/{id} [delete]
 // @Security Bearer
 func (a UserSessionHandler) Logout(c *gin.Context) {
-	t := c.GetHeader("Authorization")
-	token := strings.Replace(t, "Bearer ", "", 1)
+	token, fromCookie := a.sessionToken(c)
 	var params userPathParams
 	if err := c.BindUri(&params); err != nil {
 		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
@@ -111,9 +247,12 @@ func (a UserSessionHandler) Logout(c *gin.Context) {
 	}
 
 	if err := a.session.SignOut(params.ID, token); err != nil {
-		pathParamErr := newPathParamError(err.(validator.ValidationErrors)[0])
-		c.AbortWithStatusJSON(http.StatusBadRequest, pathParamErr.getResponse())
+		status, response := newErrResponse(err, params.ID)
+		c.AbortWithStatusJSON(status, response)
 		return
 	}
+	if fromCookie {
+		a.cookie.clear(c)
+	}
 	return
 }
@@ -69,7 +69,7 @@ func (e AuthBodyError) getResponse() errResponse {
 	var response errResponse
 	errorMsg := e.err
 	switch errorMsg.Field() {
-	case "Password":
+	case "Password", "NewPassword":
 		response = newValidationErr(
 			invalidRequestBody,
 			"有効なパスワードではありません",
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+type AuthorizationCodes struct {
+	ID            string       `gorm:"type:varchar(36);primaryKey;not null"`
+	UserAccountID string       `gorm:"type:varchar(36);not null"`
+	ClientID      string       `gorm:"not null"`
+	RedirectURI   string       `gorm:"type:text;not null"`
+	Scope         string       `gorm:"not null"`
+	CodeChallenge string       `gorm:"type:varchar(128);not null"`
+	ExpiredAt     time.Time    `gorm:"type:datetime(0);not null"`
+	CreatedAt     time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
+	UserAccount   UserAccounts `gorm:"foreignKey:UserAccountID;constraint:OnDelete:CASCADE"`
+}
+
+func NewAuthorizationCodeRepository(client gorm.DB) AuthorizationCodeRepository {
+	return AuthorizationCodeRepository{
+		client: client,
+	}
+}
+
+type AuthorizationCodeRepository struct {
+	client gorm.DB
+}
+
+func (r AuthorizationCodeRepository) Insert(code models.AuthorizationCode) (string, error) {
+	result := r.client.
+		Create(
+			AuthorizationCodes{
+				ID:            code.Code(),
+				UserAccountID: code.AccountID(),
+				ClientID:      code.ClientID(),
+				RedirectURI:   code.RedirectURI(),
+				Scope:         code.Scope(),
+				CodeChallenge: code.CodeChallenge(),
+				ExpiredAt:     code.ExpiredAt(),
+			},
+		)
+	if err := result.Error; err != nil {
+		switch {
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return "", services.NewApplicationErr(services.DuplicateToken, err)
+		default:
+			return "", services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	return code.Code(), nil
+}
+
+// Consume 認可コードは1度しか使えないため、取得と同時に削除する
+func (r AuthorizationCodeRepository) Consume(code string, now time.Time) (*models.AuthorizationCode, error) {
+	var c AuthorizationCodes
+	err := r.client.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND ? < expired_at", code, now).
+			First(&c)
+		if err := result.Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&AuthorizationCodes{ID: c.ID}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoAuthorizationCode, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := models.NewAuthorizationCode(
+		c.ID, c.UserAccountID, c.ClientID, c.RedirectURI, c.Scope, c.CodeChallenge, c.ExpiredAt,
+	)
+	return &response, nil
+}
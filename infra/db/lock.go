@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+
+	"auth-test/services"
+)
+
+func NewLocker(client gorm.DB) Locker {
+	return Locker{
+		client: client,
+	}
+}
+
+// Locker MySQLのGET_LOCKで複数のインスタンス間の排他制御を行う
+// ロックは接続ごとに保持されるため、解放するまで専用の接続を確保する
+type Locker struct {
+	client gorm.DB
+}
+
+func (l Locker) TryLock(name string) (func(), bool, error) {
+	return l.acquire(name, 0)
+}
+
+// Lock GET_LOCKの待機時間は秒単位のため、1秒未満は切り上げる
+func (l Locker) Lock(name string, timeout time.Duration) (func(), error) {
+	release, ok, err := l.acquire(name, int(math.Ceil(timeout.Seconds())))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, services.NewApplicationErr(services.LockTimeout, fmt.Errorf("ロック: %s", name))
+	}
+	return release, nil
+}
+
+func (l Locker) acquire(name string, timeout int) (func(), bool, error) {
+	sqlDB, err := l.client.DB()
+	if err != nil {
+		return nil, false, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+
+	var acquired sql.NullInt64
+	if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, timeout).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, false, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	if acquired.Int64 != 1 {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		var released sql.NullInt64
+		_ = conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", name).Scan(&released)
+		_ = conn.Close()
+	}
+	return release, true, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// Clients リダイレクトURI、グラント、スコープはスペース区切りで保存する
+type Clients struct {
+	ID           string    `gorm:"type:varchar(36);primaryKey;not null"`
+	Name         string    `gorm:"not null"`
+	Hash         string    `gorm:"not null"`
+	RedirectURIs string    `gorm:"type:text;not null"`
+	GrantTypes   string    `gorm:"not null"`
+	Scopes       string    `gorm:"type:text;not null"`
+	CreatedAt    time.Time `gorm:"type:datetime(0);not null;default:current_timestamp"`
+}
+
+func NewClientRepository(client gorm.DB) ClientRepository {
+	return ClientRepository{
+		client: client,
+	}
+}
+
+type ClientRepository struct {
+	client gorm.DB
+}
+
+func (r ClientRepository) Find(id string) (*models.Client, error) {
+	var c Clients
+	result := r.client.Where("id = ?", id).First(&c)
+	if err := result.Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoClientRecord, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := models.NewClient(
+		c.ID, c.Name, c.Hash, strings.Fields(c.RedirectURIs), strings.Fields(c.GrantTypes), strings.Fields(c.Scopes),
+	)
+	return &response, nil
+}
+
+func (r ClientRepository) Insert(client models.Client) (*models.Client, error) {
+	// 公開クライアントはシークレットを持たないため空のまま保存する
+	var hash string
+	if !client.IsPublic() {
+		encryptedSecret, err := models.NewEncryption(client.Secret())
+		if err != nil {
+			return nil, services.NewApplicationErr(services.TooLongPassword, err)
+		}
+		hash = encryptedSecret.Hash()
+	}
+
+	result := r.client.Create(
+		Clients{
+			ID:           client.ID(),
+			Name:         client.Name(),
+			Hash:         hash,
+			RedirectURIs: strings.Join(client.RedirectURIs(), " "),
+			GrantTypes:   strings.Join(client.GrantTypes(), " "),
+			Scopes:       strings.Join(client.Scopes(), " "),
+		},
+	)
+	if err := result.Error; err != nil {
+		switch {
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return nil, services.NewApplicationErr(services.DuplicateClient, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	return r.Find(client.ID())
+}
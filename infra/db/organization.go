@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// Organizations hostはHostヘッダからテナントを特定しない組織ではNULLとなる
+type Organizations struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey;not null"`
+	Slug      string    `gorm:"type:varchar(64);unique;not null"`
+	Name      string    `gorm:"not null"`
+	Host      *string   `gorm:"type:varchar(255);unique"`
+	CreatedAt time.Time `gorm:"type:datetime(0);not null;default:current_timestamp"`
+}
+
+func (o Organizations) toModel() models.Organization {
+	var host string
+	if o.Host != nil {
+		host = *o.Host
+	}
+	return models.NewOrganization(o.ID, o.Slug, o.Name, host)
+}
+
+func NewOrganizationRepository(client gorm.DB) OrganizationRepository {
+	return OrganizationRepository{
+		client: client,
+	}
+}
+
+type OrganizationRepository struct {
+	client gorm.DB
+}
+
+func (r OrganizationRepository) Find(id string) (*models.Organization, error) {
+	return r.find("id = ?", id)
+}
+
+func (r OrganizationRepository) FindBySlug(slug string) (*models.Organization, error) {
+	return r.find("slug = ?", slug)
+}
+
+func (r OrganizationRepository) FindByHost(host string) (*models.Organization, error) {
+	return r.find("host = ?", host)
+}
+
+func (r OrganizationRepository) Insert(organization models.Organization) (*models.Organization, error) {
+	record := Organizations{ID: organization.ID(), Slug: organization.Slug(), Name: organization.Name()}
+	if organization.Host() != "" {
+		host := organization.Host()
+		record.Host = &host
+	}
+
+	result := r.client.Create(&record)
+	if err := result.Error; err != nil {
+		switch {
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return nil, services.NewApplicationErr(services.DuplicateTenant, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := record.toModel()
+	return &response, nil
+}
+
+func (r OrganizationRepository) find(query string, value string) (*models.Organization, error) {
+	var organization Organizations
+	result := r.client.Where(query, value).First(&organization)
+	if err := result.Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoTenantRecord, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := organization.toModel()
+	return &response, nil
+}
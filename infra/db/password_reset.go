@@ -0,0 +1,93 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// PasswordResetTokens トークンはハッシュ値のみ保存する
+type PasswordResetTokens struct {
+	Hash          string       `gorm:"type:char(64);primaryKey;not null"`
+	UserAccountID string       `gorm:"type:varchar(36);not null;index"`
+	ExpiredAt     time.Time    `gorm:"type:datetime(0);not null;index"`
+	CreatedAt     time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
+	UserAccount   UserAccounts `gorm:"foreignKey:UserAccountID;constraint:OnDelete:CASCADE"`
+}
+
+func NewPasswordResetTokenRepository(client gorm.DB) PasswordResetTokenRepository {
+	return PasswordResetTokenRepository{
+		client: client,
+	}
+}
+
+type PasswordResetTokenRepository struct {
+	client gorm.DB
+}
+
+func (r PasswordResetTokenRepository) Insert(token models.PasswordResetToken) error {
+	result := r.client.Create(&PasswordResetTokens{
+		Hash:          token.Hash(),
+		UserAccountID: token.AccountID(),
+		ExpiredAt:     token.ExpiredAt(),
+	})
+	if err := result.Error; err != nil {
+		switch {
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return services.NewApplicationErr(services.DuplicateToken, err)
+		default:
+			return services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+	return nil
+}
+
+// Consume トークンは1度しか使えないため、取得と同時に削除する
+func (r PasswordResetTokenRepository) Consume(hash string, now time.Time) (*models.PasswordResetToken, error) {
+	var t PasswordResetTokens
+	err := r.client.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND ? < expired_at", hash, now).
+			First(&t)
+		if err := result.Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&PasswordResetTokens{Hash: t.Hash}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoResetToken, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := models.NewPasswordResetToken(t.Hash, t.UserAccountID, t.ExpiredAt)
+	return &response, nil
+}
+
+func (r PasswordResetTokenRepository) DeleteByOwner(owner string) error {
+	result := r.client.Where("user_account_id = ?", owner).Delete(&PasswordResetTokens{})
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのトークンをlimit件まで削除する
+func (r PasswordResetTokenRepository) DeleteExpired(now time.Time, limit int) (int64, error) {
+	result := r.client.Exec("DELETE FROM password_reset_tokens WHERE expired_at <= ? LIMIT ?", now, limit)
+	if result.Error != nil {
+		return 0, services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return result.RowsAffected, nil
+}
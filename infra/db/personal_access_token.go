@@ -0,0 +1,134 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// PersonalAccessTokens トークンはハッシュ値のみ保存する
+type PersonalAccessTokens struct {
+	ID             string       `gorm:"type:varchar(36);primaryKey;not null"`
+	OrganizationID string       `gorm:"type:varchar(36);not null"`
+	UserAccountID  string       `gorm:"type:varchar(36);not null;index"`
+	Name           string       `gorm:"not null"`
+	Hash           string       `gorm:"type:char(64);unique;not null"`
+	Scope          string       `gorm:"not null;default:''"`
+	ExpiredAt      time.Time    `gorm:"type:datetime(0);not null"`
+	RevokedAt      *time.Time   `gorm:"type:datetime(0)"`
+	CreatedAt      time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
+	UserAccount    UserAccounts `gorm:"foreignKey:UserAccountID;constraint:OnDelete:CASCADE"`
+}
+
+func (t PersonalAccessTokens) toModel() models.PersonalAccessToken {
+	var revokedAt time.Time
+	if t.RevokedAt != nil {
+		revokedAt = *t.RevokedAt
+	}
+	return models.NewPersonalAccessToken(
+		t.ID, t.OrganizationID, t.UserAccountID, t.Name, t.Scope, t.CreatedAt, t.ExpiredAt, revokedAt,
+	)
+}
+
+func NewPersonalAccessTokenRepository(client gorm.DB) PersonalAccessTokenRepository {
+	return PersonalAccessTokenRepository{
+		client: client,
+	}
+}
+
+type PersonalAccessTokenRepository struct {
+	client gorm.DB
+}
+
+func (r PersonalAccessTokenRepository) Insert(
+	token models.PersonalAccessToken, hash string,
+) (*models.PersonalAccessToken, error) {
+	record := PersonalAccessTokens{
+		ID:             token.ID(),
+		OrganizationID: token.OrganizationID(),
+		UserAccountID:  token.OwnerID(),
+		Name:           token.Name(),
+		Hash:           hash,
+		Scope:          token.Scope(),
+		ExpiredAt:      token.ExpiredAt(),
+		CreatedAt:      token.IssuedAt(),
+	}
+	result := r.client.Omit("UserAccount").Create(&record)
+	if err := result.Error; err != nil {
+		switch {
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return nil, services.NewApplicationErr(services.DuplicateToken, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := record.toModel()
+	return &response, nil
+}
+
+func (r PersonalAccessTokenRepository) FindByHash(hash string) (*models.PersonalAccessToken, error) {
+	var token PersonalAccessTokens
+	result := r.client.Where("hash = ?", hash).First(&token)
+	if err := result.Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoTokenRecord, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	response := token.toModel()
+	return &response, nil
+}
+
+// ListByOwner 有効期限内かつ未失効のトークンのみ取得する
+func (r PersonalAccessTokenRepository) ListByOwner(owner string, now time.Time) ([]models.PersonalAccessToken, error) {
+	var tokens []PersonalAccessTokens
+	result := r.client.
+		Where("user_account_id = ? AND ? < expired_at AND revoked_at IS NULL", owner, now).
+		Order("created_at").
+		Find(&tokens)
+	if err := result.Error; err != nil {
+		return nil, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+
+	results := make([]models.PersonalAccessToken, 0, len(tokens))
+	for _, token := range tokens {
+		results = append(results, token.toModel())
+	}
+	return results, nil
+}
+
+// Revoke 持ち主以外は失効できないよう、持ち主とIDの両方で絞り込む
+func (r PersonalAccessTokenRepository) Revoke(owner, id string, now time.Time) error {
+	result := r.client.
+		Model(&PersonalAccessTokens{}).
+		Where("id = ? AND user_account_id = ? AND revoked_at IS NULL", id, owner).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	} else if result.RowsAffected == NoDeleteRecords {
+		return services.NewApplicationErr(services.NoTokenRecord, fmt.Errorf("失効対象: %s", id))
+	}
+	return nil
+}
+
+// RevokeByOwner 持ち主の未失効のトークンを全て失効させる。対象が無くてもエラーにしない
+func (r PersonalAccessTokenRepository) RevokeByOwner(owner string, now time.Time) error {
+	result := r.client.
+		Model(&PersonalAccessTokens{}).
+		Where("user_account_id = ? AND revoked_at IS NULL", owner).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
+}
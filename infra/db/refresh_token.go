@@ -20,7 +20,11 @@ const (
 type Tokens struct {
 	ID            string       `gorm:"type:varchar(36);primaryKey;not null"`
 	UserAccountID string       `gorm:"type:varchar(36);not null;constraint:OnDelete:CASCADE"`
+	FamilyID      string       `gorm:"type:varchar(36);not null;index"`
+	ClientID      string       `gorm:"type:varchar(36);not null;default:''"`
+	Scope         string       `gorm:"not null;default:''"`
 	ExpiredAt     time.Time    `gorm:"type:datetime(0);not null"`
+	RevokedAt     *time.Time   `gorm:"type:datetime(0)"`
 	CreatedAt     time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
 	UserAccount   UserAccounts `gorm:"foreignKey:UserAccountID;constraint:OnDelete:CASCADE"`
 }
@@ -41,6 +45,9 @@ func (r TokenRepository) Insert(refreshToken models.RefreshTokenInput) (string,
 			Tokens{
 				ID:            refreshToken.Value(),
 				UserAccountID: refreshToken.AccountID(),
+				FamilyID:      refreshToken.FamilyID(),
+				ClientID:      refreshToken.ClientID(),
+				Scope:         refreshToken.Scope(),
 				ExpiredAt:     refreshToken.ExpiredAt(),
 			},
 		)
@@ -82,21 +89,125 @@ func (r TokenRepository) Delete(refreshToken string) error {
 	return nil
 }
 
-func (r TokenRepository) FindOwner(refreshToken string, now time.Time) (*models.TokenOwner, error) {
+// FindOwner 有効期限内かつ未失効のトークンのみ取得する
+func (r TokenRepository) FindOwner(refreshToken string, now time.Time) (*models.RefreshToken, error) {
 	var token Tokens
 	result := r.client.
 		Table("tokens").
-		Where("id = ? AND ? < expired_at", refreshToken, now.String()).
+		Where("id = ? AND ? < expired_at AND revoked_at IS NULL", refreshToken, now.String()).
 		Preload("UserAccount").
 		First(&token)
 	if err := result.Error; err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
-			return nil, services.NewApplicationErr(services.NoUserRecord, err)
+			return nil, services.NewApplicationErr(services.NoTokenRecord, err)
 		default:
 			return nil, services.NewApplicationErr(services.InternalServerErr, err)
 		}
 	}
-	response := models.NewTokenOwner(token.UserAccount.ID, token.UserAccount.Email)
-	return &response, nil
+	return newRefreshToken(token), nil
+}
+
+// Find 更新済みのトークンの再利用を検知するため、失効済みや期限切れのトークンも取得する
+func (r TokenRepository) Find(refreshToken string) (*models.RefreshToken, error) {
+	var token Tokens
+	result := r.client.
+		Table("tokens").
+		Where("id = ?", refreshToken).
+		Preload("UserAccount").
+		First(&token)
+	if err := result.Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoTokenRecord, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+
+	return newRefreshToken(token), nil
+}
+
+// Revoke 未失効のトークンのみ失効させる。同時に更新された場合は片方が失効済みエラーになる
+func (r TokenRepository) Revoke(refreshToken string, now time.Time) error {
+	result := r.client.
+		Table("tokens").
+		Where("id = ? AND revoked_at IS NULL", refreshToken).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	} else if result.RowsAffected == NoDeleteRecords {
+		return services.NewApplicationErr(services.RevokedToken, fmt.Errorf("失効対象: %s", refreshToken))
+	}
+	return nil
+}
+
+// RevokeFamily 空のファミリーIDでは無関係なトークンまで失効させるため、何もしない
+func (r TokenRepository) RevokeFamily(familyID string, now time.Time) error {
+	if familyID == "" {
+		return nil
+	}
+
+	result := r.client.
+		Table("tokens").
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
+}
+
+// ListByOwner 有効期限内かつ未失効のトークンのみ取得する
+func (r TokenRepository) ListByOwner(owner string) ([]models.RefreshToken, error) {
+	var tokens []Tokens
+	result := r.client.
+		Table("tokens").
+		Where("user_account_id = ? AND ? < expired_at AND revoked_at IS NULL", owner, time.Now().UTC()).
+		Preload("UserAccount").
+		Order("created_at").
+		Find(&tokens)
+	if err := result.Error; err != nil {
+		return nil, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+
+	results := make([]models.RefreshToken, 0, len(tokens))
+	for _, token := range tokens {
+		results = append(results, *newRefreshToken(token))
+	}
+	return results, nil
+}
+
+func (r TokenRepository) DeleteByOwner(owner string) error {
+	result := r.client.
+		Unscoped().
+		Where("user_account_id = ?", owner).
+		Delete(&Tokens{})
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのトークンをlimit件まで削除する
+// 失効済みでも期限内のトークンは再利用の検知に利用するため削除しない
+func (r TokenRepository) DeleteExpired(now time.Time, limit int) (int64, error) {
+	result := r.client.Exec("DELETE FROM tokens WHERE expired_at <= ? LIMIT ?", now, limit)
+	if result.Error != nil {
+		return 0, services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func newRefreshToken(token Tokens) *models.RefreshToken {
+	var revokedAt time.Time
+	if token.RevokedAt != nil {
+		revokedAt = *token.RevokedAt
+	}
+	response := models.NewRefreshToken(
+		token.ID, token.FamilyID, token.ClientID, token.Scope,
+		models.NewTokenOwner(token.UserAccount.OrganizationID, token.UserAccount.ID, token.UserAccount.Email),
+		token.CreatedAt, token.ExpiredAt, revokedAt,
+	)
+	return &response
 }
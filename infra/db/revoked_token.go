@@ -0,0 +1,50 @@
+package db
+
+import (
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"auth-test/services"
+)
+
+// RevokedTokens IDトークンの有効期限を過ぎた行は検証に不要となる
+type RevokedTokens struct {
+	ID        string    `gorm:"type:varchar(36);primaryKey;not null"`
+	ExpiredAt time.Time `gorm:"type:datetime(0);not null;index"`
+	CreatedAt time.Time `gorm:"type:datetime(0);not null;default:current_timestamp"`
+}
+
+func NewRevokedTokenRepository(client gorm.DB) RevokedTokenRepository {
+	return RevokedTokenRepository{
+		client: client,
+	}
+}
+
+type RevokedTokenRepository struct {
+	client gorm.DB
+}
+
+func (r RevokedTokenRepository) Insert(tokenID string, expiredAt time.Time) error {
+	result := r.client.Create(&RevokedTokens{ID: tokenID, ExpiredAt: expiredAt})
+	if err := result.Error; err != nil {
+		switch {
+		// 既に失効済みの場合は何もしない
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return nil
+		default:
+			return services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+	return nil
+}
+
+func (r RevokedTokenRepository) Exists(tokenID string) (bool, error) {
+	var count int64
+	result := r.client.Model(&RevokedTokens{}).Where("id = ?", tokenID).Count(&count)
+	if err := result.Error; err != nil {
+		return false, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+
+	"auth-test/services"
+)
+
+type Roles struct {
+	ID   uint   `gorm:"primaryKey;autoIncrement"`
+	Name string `gorm:"type:varchar(64);unique;not null"`
+}
+
+// UserRoles ユーザとロールの割り当て
+type UserRoles struct {
+	UserAccountID string `gorm:"type:varchar(36);primaryKey;not null"`
+	RoleID        uint   `gorm:"primaryKey;not null"`
+}
+
+func NewRoleRepository(client gorm.DB) RoleRepository {
+	return RoleRepository{
+		client: client,
+	}
+}
+
+type RoleRepository struct {
+	client gorm.DB
+}
+
+func (r RoleRepository) FindByAccount(accountID string) ([]string, error) {
+	var roles []string
+	result := r.client.Model(&Roles{}).
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_account_id = ?", accountID).
+		Order("roles.name").
+		Pluck("roles.name", &roles)
+	if err := result.Error; err != nil {
+		return nil, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return roles, nil
+}
+
+func (r RoleRepository) Assign(accountID, name string) error {
+	role, err := r.find(name)
+	if err != nil {
+		return err
+	}
+
+	result := r.client.Create(&UserRoles{UserAccountID: accountID, RoleID: role.ID})
+	if err := result.Error; err != nil {
+		switch {
+		// 既に割り当て済みの場合は何もしない
+		case err.(*mysql.MySQLError).Number == MySQLDuplicateEntry:
+			return nil
+		default:
+			return services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+	return nil
+}
+
+func (r RoleRepository) Unassign(accountID, name string) error {
+	role, err := r.find(name)
+	if err != nil {
+		return err
+	}
+
+	result := r.client.Where("user_account_id = ? AND role_id = ?", accountID, role.ID).Delete(&UserRoles{})
+	if err := result.Error; err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return nil
+}
+
+func (r RoleRepository) find(name string) (*Roles, error) {
+	var role Roles
+	result := r.client.Where("name = ?", name).First(&role)
+	if err := result.Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoRoleRecord, fmt.Errorf("role: %s", name))
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
+	}
+	return &role, nil
+}
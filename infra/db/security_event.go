@@ -0,0 +1,41 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+type SecurityEvents struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement"`
+	Type          string    `gorm:"type:varchar(64);not null;index"`
+	UserAccountID string    `gorm:"type:varchar(36);not null;index"`
+	Detail        string    `gorm:"type:text;not null"`
+	OccurredAt    time.Time `gorm:"type:datetime(0);not null"`
+}
+
+func NewSecurityEventRepository(client gorm.DB) SecurityEventRepository {
+	return SecurityEventRepository{
+		client: client,
+	}
+}
+
+type SecurityEventRepository struct {
+	client gorm.DB
+}
+
+func (r SecurityEventRepository) Record(event models.SecurityEvent) error {
+	result := r.client.Create(&SecurityEvents{
+		Type:          event.Type(),
+		UserAccountID: event.AccountID(),
+		Detail:        event.Detail(),
+		OccurredAt:    event.OccurredAt(),
+	})
+	if err := result.Error; err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return nil
+}
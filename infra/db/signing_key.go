@@ -0,0 +1,76 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// SigningKeys ローテーションで生成した署名鍵。鍵そのものは暗号化して保存する
+type SigningKeys struct {
+	ID        string     `gorm:"type:varchar(64);primaryKey;not null"`
+	Algorithm string     `gorm:"type:varchar(16);not null"`
+	Material  string     `gorm:"type:text;not null"`
+	RetiredAt *time.Time `gorm:"type:datetime(0);index"`
+	CreatedAt time.Time  `gorm:"type:datetime(0);not null;default:current_timestamp"`
+}
+
+func (k SigningKeys) toModel() models.SigningKey {
+	var retiredAt time.Time
+	if k.RetiredAt != nil {
+		retiredAt = *k.RetiredAt
+	}
+	return models.NewSigningKey(k.ID, k.Algorithm, k.Material, k.CreatedAt, retiredAt)
+}
+
+func NewSigningKeyRepository(client gorm.DB) SigningKeyRepository {
+	return SigningKeyRepository{
+		client: client,
+	}
+}
+
+type SigningKeyRepository struct {
+	client gorm.DB
+}
+
+// Rotate 同時にローテーションしても署名に使う鍵が1つになるよう、退役と登録を同じトランザクションで行う
+func (r SigningKeyRepository) Rotate(key models.SigningKey, retiredAt time.Time) error {
+	err := r.client.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Model(&SigningKeys{}).
+			Where("retired_at IS NULL").
+			Update("retired_at", retiredAt)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		return tx.Create(&SigningKeys{
+			ID:        key.ID(),
+			Algorithm: key.Algorithm(),
+			Material:  key.Material(),
+			CreatedAt: key.CreatedAt(),
+		}).Error
+	})
+	if err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return nil
+}
+
+// List 署名に使う鍵が最後になるよう、退役した鍵を先に登録した順に並べる
+func (r SigningKeyRepository) List() ([]models.SigningKey, error) {
+	var keys []SigningKeys
+	result := r.client.Order("retired_at IS NULL").Order("created_at").Find(&keys)
+	if err := result.Error; err != nil {
+		return nil, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+
+	results := make([]models.SigningKey, 0, len(keys))
+	for _, key := range keys {
+		results = append(results, key.toModel())
+	}
+	return results, nil
+}
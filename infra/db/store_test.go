@@ -0,0 +1,61 @@
+package db_test
+
+import (
+	"os"
+	"testing"
+
+	"auth-test/infra/db"
+	"auth-test/infra/storetest"
+	"auth-test/models"
+)
+
+// TestConformance TEST_MYSQL_DSN にテスト用のDBを指定した場合のみ実行する
+// 例: root:password@tcp(127.0.0.1:3306)/auth_test?charset=utf8mb4&parseTime=True&loc=UTC
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN が指定されていません")
+	}
+
+	client, err := db.NewClient(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.AutoMigrate(
+		&db.Organizations{}, &db.UserAccounts{}, &db.UserSessions{}, &db.Tokens{}, &db.RevokedTokens{},
+		&db.SecurityEvents{}, &db.Clients{}, &db.AuthorizationCodes{}, &db.Roles{}, &db.UserRoles{},
+		&db.PersonalAccessTokens{}, &db.PasswordResetTokens{}, &db.SigningKeys{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.
+		Where(db.Organizations{ID: models.DefaultOrganizationID}).
+		FirstOrCreate(&db.Organizations{Slug: "default", Name: "default"}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range models.DefinedRoles {
+		if err = client.Where(db.Roles{Name: role}).FirstOrCreate(&db.Roles{}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	storetest.Run(t, func(t *testing.T) storetest.Accessors {
+		return storetest.Accessors{
+			UserAccount:   db.NewUserAccountRepository(*client),
+			UserSession:   db.NewUserSessionRepo(*client),
+			Token:         db.NewTokenRepository(*client),
+			Revoked:       db.NewRevokedTokenRepository(*client),
+			Code:          db.NewAuthorizationCodeRepository(*client),
+			Client:        db.NewClientRepository(*client),
+			Organization:  db.NewOrganizationRepository(*client),
+			Role:          db.NewRoleRepository(*client),
+			PersonalToken: db.NewPersonalAccessTokenRepository(*client),
+			Event:         db.NewSecurityEventRepository(*client),
+			ResetToken:    db.NewPasswordResetTokenRepository(*client),
+			SigningKey:    db.NewSigningKeyRepository(*client),
+			Locker:        db.NewLocker(*client),
+		}
+	})
+}
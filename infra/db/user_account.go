@@ -3,6 +3,7 @@ package db
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
@@ -12,23 +13,41 @@ import (
 	"auth-test/services"
 )
 
+// UserAccounts emailは組織ごとに一意とする
 type UserAccounts struct {
-	ID    string `gorm:"type:varchar(36);primaryKey;not null"`
-	Email string `gorm:"unique;not null"`
-	Name  string `gorm:"not null"`
-	Hash  string `gorm:"not null"`
+	ID             string     `gorm:"type:varchar(36);primaryKey;not null"`
+	OrganizationID string     `gorm:"type:varchar(36);uniqueIndex:idx_organization_email;not null;default:'00000000-0000-0000-0000-000000000000'"`
+	Email          string     `gorm:"type:varchar(191);uniqueIndex:idx_organization_email;not null"`
+	Name           string     `gorm:"not null"`
+	Hash           string     `gorm:"not null"`
+	EmailVerified  bool       `gorm:"not null;default:false"`
+	ResetRequired  bool       `gorm:"not null;default:false"`
+	TokenGen       int        `gorm:"column:token_generation;not null;default:0"`
+	DisabledAt     *time.Time `gorm:"type:datetime(0)"`
 	gorm.Model
 }
 
-func NewUserAccount(id, email, name, passwordHash string) *UserAccounts {
+func NewUserAccount(orgID, id, email, name, passwordHash string) *UserAccounts {
 	return &UserAccounts{
-		ID:    id,
-		Email: email,
-		Name:  name,
-		Hash:  passwordHash,
+		ID:             id,
+		OrganizationID: orgID,
+		Email:          email,
+		Name:           name,
+		Hash:           passwordHash,
 	}
 }
 
+func (a UserAccounts) toModel() models.UserAccount {
+	var disabledAt time.Time
+	if a.DisabledAt != nil {
+		disabledAt = *a.DisabledAt
+	}
+	return models.NewStoredUserAccount(
+		a.OrganizationID, a.ID, a.Email, a.Name, a.Hash, a.EmailVerified, a.ResetRequired, a.TokenGen,
+		disabledAt, a.UpdatedAt,
+	)
+}
+
 type UserAccountRepository struct {
 	mysql gorm.DB
 }
@@ -49,13 +68,13 @@ func (r *UserAccountRepository) Find(id string) (*models.UserAccount, error) {
 		}
 	}
 
-	response := models.NewUserAccount(account.ID, account.Email, account.Name, account.Hash)
+	response := account.toModel()
 	return &response, nil
 }
 
-func (r *UserAccountRepository) FindByEmail(email string) (*models.UserAccount, error) {
+func (r *UserAccountRepository) FindByEmail(orgID, email string) (*models.UserAccount, error) {
 	var account UserAccounts
-	result := r.mysql.Where("email=?", email).First(&account)
+	result := r.mysql.Where("organization_id=? AND email=?", orgID, email).First(&account)
 	if err := result.Error; err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
@@ -65,31 +84,30 @@ func (r *UserAccountRepository) FindByEmail(email string) (*models.UserAccount,
 		}
 	}
 
-	response := models.NewUserAccount(account.ID, account.Email, account.Name, account.Hash)
+	response := account.toModel()
 	return &response, nil
 }
 
-func (r *UserAccountRepository) List() ([]models.UserAccount, error) {
+func (r *UserAccountRepository) List(orgID string) ([]models.UserAccount, error) {
 	var accounts []UserAccounts
-	result := r.mysql.Find(&accounts)
+	result := r.mysql.Where("organization_id=?", orgID).Find(&accounts)
 	if err := result.Error; err != nil {
 		return nil, services.NewApplicationErr(services.NoUsersRecord, err)
 	}
 	var results []models.UserAccount
 	for _, account := range accounts {
-		a := models.NewUserAccount(account.ID, account.Email, account.Name, account.Hash)
-		results = append(results, a)
+		results = append(results, account.toModel())
 	}
 
 	return results, nil
 }
 
-func (r *UserAccountRepository) Insert(id, email, name, password string) (*models.UserAccount, error) {
+func (r *UserAccountRepository) Insert(orgID, id, email, name, password string) (*models.UserAccount, error) {
 	encryptedPass, err := models.NewEncryption(password)
 	if err != nil {
 		return nil, services.NewApplicationErr(services.TooLongPassword, err)
 	}
-	account := NewUserAccount(id, email, name, encryptedPass.Hash())
+	account := NewUserAccount(orgID, id, email, name, encryptedPass.Hash())
 
 	result := r.mysql.Create(account)
 	if err = result.Error; err != nil {
@@ -102,7 +120,7 @@ func (r *UserAccountRepository) Insert(id, email, name, password string) (*model
 	}
 
 	var a UserAccounts
-	result = r.mysql.Where("email = ?", email).Find(&a)
+	result = r.mysql.Where("id = ?", id).Find(&a)
 	if err = result.Error; err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
@@ -112,20 +130,33 @@ func (r *UserAccountRepository) Insert(id, email, name, password string) (*model
 		}
 	}
 
-	response := models.NewUserAccount(a.ID, a.Email, a.Name, a.Hash)
+	response := a.toModel()
 	return &response, nil
 }
 
+// Update メールアドレスと名前のみ更新する。パスワードはUpdatePasswordで更新する
 func (r *UserAccountRepository) Update(account models.UserAccount) (*models.UserAccount, error) {
-	encryptedPass, err := models.NewEncryption(account.Password())
-	if err != nil {
-		return nil, services.NewApplicationErr(services.TooLongPassword, err)
+	var current UserAccounts
+	if err := r.mysql.Where("id = ?", account.ID()).First(&current).Error; err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, services.NewApplicationErr(services.NoUserRecord, err)
+		default:
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
+		}
 	}
 
-	newAccount := UserAccounts{Email: account.Email(), Name: account.Name(), Hash: encryptedPass.Hash()}
+	// メールアドレスを変更した場合は確認し直すまで未確認とする
+	// UpdateColumnsでは更新日時が自動で設定されないため、UserInfoのupdated_atとして明示的に更新する
+	newAccount := map[string]interface{}{
+		"email":          account.Email(),
+		"email_verified": current.EmailVerified && current.Email == account.Email(),
+		"name":           account.Name(),
+		"updated_at":     time.Now(),
+	}
 	var a UserAccounts
 	result := r.mysql.Table("user_accounts").Where("id = ?", account.ID()).UpdateColumns(newAccount).First(&a)
-	if err = result.Error; err != nil {
+	if err := result.Error; err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
 			return nil, services.NewApplicationErr(services.NoUserRecord, err)
@@ -136,10 +167,20 @@ func (r *UserAccountRepository) Update(account models.UserAccount) (*models.User
 		}
 	}
 
-	response := models.NewUserAccount(a.ID, a.Email, a.Name, a.Hash)
+	response := a.toModel()
 	return &response, nil
 }
 
+// UpdatePassword パスワードを設定し直したため、再設定の要求は解除する
+func (r *UserAccountRepository) UpdatePassword(id, password string) error {
+	encryptedPass, err := models.NewEncryption(password)
+	if err != nil {
+		return services.NewApplicationErr(services.TooLongPassword, err)
+	}
+
+	return r.updateColumns(id, map[string]interface{}{"hash": encryptedPass.Hash(), "reset_required": false})
+}
+
 func (r *UserAccountRepository) Delete(id string) error {
 	deletedUUID, err := uuid.Parse(id)
 	if err != nil {
@@ -154,3 +195,44 @@ func (r *UserAccountRepository) Delete(id string) error {
 	}
 	return nil
 }
+
+func (r *UserAccountRepository) RequirePasswordReset(id string) error {
+	return r.updateColumn(id, "reset_required", true)
+}
+
+func (r *UserAccountRepository) VerifyEmail(id string) error {
+	return r.updateColumn(id, "email_verified", true)
+}
+
+func (r *UserAccountRepository) Disable(id string, now time.Time) error {
+	return r.updateColumn(id, "disabled_at", now)
+}
+
+func (r *UserAccountRepository) Enable(id string) error {
+	return r.updateColumn(id, "disabled_at", nil)
+}
+
+// IncrementTokenGeneration 同時に実行されても世代を取りこぼさないよう、DB上で加算する
+func (r *UserAccountRepository) IncrementTokenGeneration(id string) error {
+	return r.updateColumn(id, "token_generation", gorm.Expr("token_generation + 1"))
+}
+
+func (r *UserAccountRepository) updateColumn(id, column string, value interface{}) error {
+	return r.updateColumns(id, map[string]interface{}{column: value})
+}
+
+// updateColumns MySQLは値が変わらない行を更新件数に含めないため、存在の確認は更新とは別に行う
+func (r *UserAccountRepository) updateColumns(id string, values map[string]interface{}) error {
+	var count int64
+	if err := r.mysql.Model(&UserAccounts{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	if count == 0 {
+		return services.NewApplicationErr(services.NoUserRecord, fmt.Errorf("更新対象ID: %s", id))
+	}
+
+	if err := r.mysql.Model(&UserAccounts{}).Where("id = ?", id).UpdateColumns(values).Error; err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return nil
+}
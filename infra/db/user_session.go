@@ -12,12 +12,27 @@ import (
 	"auth-test/services"
 )
 
+// userAgentLength UserAgentカラムに保存する最大の長さ
+const userAgentLength = 255
+
+// UserSessions ExpiredAtはリクエストの都度延長し、AbsoluteExpiredAtを超えて延長しない
+// LastSeenAtはExpiredAtを延長した時点で更新する
 type UserSessions struct {
-	ID          string       `gorm:"type:varchar(36);primaryKey;not null"`
-	UserID      string       `gorm:"type:varchar(36);type:varchar(36);not null"`
-	ExpiredAt   time.Time    `gorm:"type:datetime(0);not null"`
-	CreatedAt   time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
-	UserAccount UserAccounts `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	ID                string       `gorm:"type:varchar(36);primaryKey;not null"`
+	UserID            string       `gorm:"type:varchar(36);type:varchar(36);not null"`
+	IPAddress         string       `gorm:"type:varchar(45);not null;default:''"`
+	UserAgent         string       `gorm:"type:varchar(255);not null;default:''"`
+	ExpiredAt         time.Time    `gorm:"type:datetime(0);not null"`
+	AbsoluteExpiredAt time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
+	LastSeenAt        time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
+	CreatedAt         time.Time    `gorm:"type:datetime(0);not null;default:current_timestamp"`
+	UserAccount       UserAccounts `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+func (s UserSessions) toModel() models.Session {
+	return models.NewStoredSession(
+		s.UserID, s.ID, s.IPAddress, s.UserAgent, s.CreatedAt, s.LastSeenAt, s.ExpiredAt, s.AbsoluteExpiredAt,
+	)
 }
 
 type Token struct {
@@ -37,9 +52,12 @@ type UserSessionRepository struct {
 func (r UserSessionRepository) Register(session models.Session) (string, error) {
 	result := r.client.Create(
 		UserSessions{
-			UserID:    session.Owner(),
-			ID:        session.Token(),
-			ExpiredAt: session.ExpiredAt(),
+			UserID:            session.Owner(),
+			ID:                session.Token(),
+			IPAddress:         session.IPAddress(),
+			UserAgent:         truncate(session.UserAgent(), userAgentLength),
+			ExpiredAt:         session.ExpiredAt(),
+			AbsoluteExpiredAt: session.AbsoluteExpiredAt(),
 		},
 	)
 	if err := result.Error; err != nil {
@@ -85,21 +103,35 @@ func (r UserSessionRepository) Verify(token string) error {
 	return nil
 }
 
-func (r UserSessionRepository) FindOwner(token string) (string, error) {
+// FindOwner 有効期限内のセッションのみ取得する
+func (r UserSessionRepository) FindOwner(token string, now time.Time) (*models.Session, error) {
 	var sess UserSessions
 	result := r.client.
-		Where("id = ? AND ? < expired_at", token, time.Now()).
+		Where("id = ? AND ? < expired_at AND ? < absolute_expired_at", token, now, now).
 		First(&sess)
 	if err := result.Error; err != nil {
 		switch {
 		case errors.Is(err, gorm.ErrRecordNotFound):
-			return "", services.NewApplicationErr(services.NoSessionRecord, err)
+			return nil, services.NewApplicationErr(services.NoSessionRecord, err)
 		default:
-			return "", services.NewApplicationErr(services.InternalServerErr, err)
+			return nil, services.NewApplicationErr(services.InternalServerErr, err)
 		}
 	}
 
-	return sess.UserID, nil
+	session := sess.toModel()
+	return &session, nil
+}
+
+// Extend 期限を短くする更新は行わない
+func (r UserSessionRepository) Extend(token string, now, expiredAt time.Time) error {
+	result := r.client.
+		Model(&UserSessions{}).
+		Where("id = ? AND expired_at < ?", token, expiredAt).
+		Updates(map[string]interface{}{"expired_at": expiredAt, "last_seen_at": now})
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
 }
 
 func (r UserSessionRepository) Delete(owner, token string) error {
@@ -115,3 +147,56 @@ func (r UserSessionRepository) Delete(owner, token string) error {
 	}
 	return nil
 }
+
+func (r UserSessionRepository) ListByOwner(owner string) ([]models.Session, error) {
+	var sessions []UserSessions
+	result := r.client.
+		Where("user_id = ? AND ? < expired_at AND ? < absolute_expired_at", owner, time.Now(), time.Now()).
+		Order("created_at").
+		Find(&sessions)
+	if err := result.Error; err != nil {
+		return nil, services.NewApplicationErr(services.InternalServerErr, err)
+	}
+
+	results := make([]models.Session, 0, len(sessions))
+	for _, sess := range sessions {
+		results = append(results, sess.toModel())
+	}
+	return results, nil
+}
+
+func (r UserSessionRepository) DeleteByOwner(owner string) error {
+	result := r.client.Unscoped().Where("user_id = ?", owner).Delete(&UserSessions{})
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
+}
+
+// DeleteOthers keepで指定したセッション以外を削除する
+func (r UserSessionRepository) DeleteOthers(owner, keep string) error {
+	result := r.client.Unscoped().Where("user_id = ? AND id <> ?", owner, keep).Delete(&UserSessions{})
+	if result.Error != nil {
+		return services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのセッションをlimit件まで削除する
+func (r UserSessionRepository) DeleteExpired(now time.Time, limit int) (int64, error) {
+	result := r.client.Exec(
+		"DELETE FROM user_sessions WHERE expired_at <= ? OR absolute_expired_at <= ? LIMIT ?", now, now, limit,
+	)
+	if result.Error != nil {
+		return 0, services.NewApplicationErr(services.InternalServerErr, result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
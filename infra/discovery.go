@@ -0,0 +1,69 @@
+package infra
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"auth-test/infra/auth"
+	"auth-test/infra/configuration"
+	"auth-test/infra/controller"
+	"auth-test/models"
+)
+
+const (
+	DiscoveryPath  = "/.well-known/openid-configuration"
+	JWKSPath       = "/v1/.well-known/jwks.json"
+	AuthorizePath  = "/v1/auth/authorize"
+	TokenPath      = "/v1/auth/token"
+	RevokePath     = "/v1/auth/revoke"
+	IntrospectPath = "/v1/auth/introspect"
+	UserInfoPath   = "/v1/auth/userinfo"
+)
+
+// newOpenIDConfiguration 実際に登録されたルートから提供している機能を組み立てる
+func newOpenIDConfiguration(env configuration.Environment, routes gin.RoutesInfo) controller.OpenIDConfiguration {
+	registered := map[string]bool{}
+	for _, r := range routes {
+		registered[r.Method+" "+r.Path] = true
+	}
+
+	config := controller.OpenIDConfiguration{
+		Issuer:                           env.Issuer,
+		ResponseTypesSupported:           []string{},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{env.SigningAlgorithm},
+		ClaimsSupported:                  append([]string{}, auth.SupportedClaims...),
+		GrantTypesSupported:              []string{},
+	}
+
+	if registered[http.MethodGet+" "+JWKSPath] {
+		config.JWKSURI = env.Issuer + JWKSPath
+	}
+	if registered[http.MethodGet+" "+AuthorizePath] && registered[http.MethodPost+" "+AuthorizePath] {
+		config.AuthorizationEndpoint = env.Issuer + AuthorizePath
+		config.ResponseTypesSupported = append(config.ResponseTypesSupported, "code")
+		config.CodeChallengeMethodsSupported = []string{models.CodeChallengeMethodS256}
+	}
+	if registered[http.MethodPost+" "+TokenPath] {
+		config.TokenEndpoint = env.Issuer + TokenPath
+		config.GrantTypesSupported = append(
+			config.GrantTypesSupported,
+			models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken, models.GrantTypeClientCredentials,
+		)
+		config.TokenEndpointAuthMethodsSupported = []string{"client_secret_basic", "client_secret_post", "none"}
+	}
+	if registered[http.MethodPost+" "+RevokePath] {
+		config.RevocationEndpoint = env.Issuer + RevokePath
+	}
+	if registered[http.MethodPost+" "+IntrospectPath] {
+		config.IntrospectionEndpoint = env.Issuer + IntrospectPath
+	}
+	if registered[http.MethodGet+" "+UserInfoPath] {
+		config.UserInfoEndpoint = env.Issuer + UserInfoPath
+		config.ScopesSupported = []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail}
+		config.ClaimsSupported = append(config.ClaimsSupported, "name", "updated_at")
+	}
+
+	return config
+}
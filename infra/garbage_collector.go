@@ -0,0 +1,52 @@
+package infra
+
+import (
+	"expvar"
+	"log"
+	"time"
+
+	"auth-test/services"
+)
+
+// gcMetrics /v1/admin/metrics で公開する期限切れデータの削除状況
+var gcMetrics = expvar.NewMap("garbage_collection")
+
+// collectGarbage 起動時とintervalごとに期限切れのデータを削除する
+func collectGarbage(gc services.GarbageCollector, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		runGarbageCollection(gc)
+	}
+}
+
+func runGarbageCollection(gc services.GarbageCollector) {
+	started := time.Now()
+	result, err := gc.Collect(started.UTC())
+	if err != nil {
+		gcMetrics.Add("errors", 1)
+		log.Printf("期限切れデータの削除に失敗。: %s \n", err.Error())
+		return
+	}
+	if result.Skipped {
+		gcMetrics.Add("skipped", 1)
+		return
+	}
+
+	gcMetrics.Add("runs", 1)
+	gcMetrics.Add("deleted_sessions", result.Sessions)
+	gcMetrics.Add("deleted_tokens", result.Tokens)
+	gcMetrics.Add("deleted_reset_tokens", result.ResetTokens)
+	gcMetrics.Set("last_run_unix", intVar(started.Unix()))
+	gcMetrics.Set("last_duration_ms", intVar(time.Since(started).Milliseconds()))
+}
+
+func intVar(value int64) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(value)
+	return v
+}
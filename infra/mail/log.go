@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+// LogMailer ローカル環境向けに、送信する代わりにファイルへ追記する。pathを指定しない場合は標準のログに出力する
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (m *LogMailer) Send(mail models.Mail) error {
+	message := fmt.Sprintf(
+		"To: %s\nSubject: %s\nDate: %s\n\n%s", mail.To(), mail.Subject(), time.Now().Format(time.RFC1123Z), mail.Body(),
+	)
+	if m.path == "" {
+		log.Printf("メールを送信。\n%s", message)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(message + "\n"); err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewSMTPMailer(host string, port int, username, password, from string) SMTPMailer {
+	return SMTPMailer{host: host, port: port, username: username, password: password, from: from}
+}
+
+// SMTPMailer usernameを指定した場合のみPLAIN認証を行う。net/smtpは接続先が対応していればSTARTTLSを使用する
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+func (m SMTPMailer) Send(mail models.Mail) error {
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	if err := smtp.SendMail(addr, auth, m.from, []string{mail.To()}, m.message(mail)); err != nil {
+		return services.NewApplicationErr(services.InternalServerErr, err)
+	}
+	return nil
+}
+
+// message 件名は日本語を含むためMIMEエンコードする
+func (m SMTPMailer) message(mail models.Mail) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", mail.Subject()))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body(), "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,26 @@
+package infra
+
+import (
+	"fmt"
+
+	"auth-test/infra/configuration"
+	"auth-test/infra/mail"
+	"auth-test/models"
+)
+
+const (
+	MailerSMTP = "smtp"
+	MailerLog  = "log"
+)
+
+// newMailer MAILERで指定した方式でメールを送信する
+func newMailer(env configuration.Environment) (models.Mailer, error) {
+	switch env.Mailer {
+	case MailerSMTP:
+		return mail.NewSMTPMailer(env.SMTPHost, env.SMTPPort, env.SMTPUser, env.SMTPPassword, env.MailFrom), nil
+	case MailerLog:
+		return mail.NewLogMailer(env.MailLogPath), nil
+	default:
+		return nil, fmt.Errorf("未対応のメール送信方式です: %s", env.Mailer)
+	}
+}
@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewAuthorizationCodeRepository(store *Store) AuthorizationCodeRepository {
+	return AuthorizationCodeRepository{store: store}
+}
+
+type AuthorizationCodeRepository struct {
+	store *Store
+}
+
+func (r AuthorizationCodeRepository) Insert(code models.AuthorizationCode) (string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.codes[code.Code()]; ok {
+		return "", services.NewApplicationErr(services.DuplicateToken, fmt.Errorf("認可コード: %s", code.Code()))
+	}
+	if _, ok := r.store.accounts[code.AccountID()]; !ok {
+		return "", services.NewApplicationErr(services.InternalServerErr, fmt.Errorf("ユーザー: %s", code.AccountID()))
+	}
+
+	r.store.codes[code.Code()] = code
+	return code.Code(), nil
+}
+
+// Consume 認可コードは1度しか使えないため、取得と同時に削除する
+func (r AuthorizationCodeRepository) Consume(code string, now time.Time) (*models.AuthorizationCode, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	c, ok := r.store.codes[code]
+	if !ok || !now.Before(c.ExpiredAt()) {
+		return nil, services.NewApplicationErr(services.NoAuthorizationCode, fmt.Errorf("認可コード: %s", code))
+	}
+	delete(r.store.codes, code)
+	return &c, nil
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"auth-test/services"
+)
+
+// lockPollInterval ロックが解放されたか確認する間隔
+const lockPollInterval = 10 * time.Millisecond
+
+func NewLocker() *Locker {
+	return &Locker{locked: map[string]bool{}}
+}
+
+// Locker プロセス内でのみ排他制御を行う
+type Locker struct {
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func (l *Locker) TryLock(name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locked[name] {
+		return nil, false, nil
+	}
+	l.locked[name] = true
+
+	release := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.locked, name)
+	}
+	return release, true, nil
+}
+
+func (l *Locker) Lock(name string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		release, ok, err := l.TryLock(name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return release, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, services.NewApplicationErr(services.LockTimeout, fmt.Errorf("ロック: %s", name))
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
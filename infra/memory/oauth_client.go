@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"fmt"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewClientRepository(store *Store) ClientRepository {
+	return ClientRepository{store: store}
+}
+
+type ClientRepository struct {
+	store *Store
+}
+
+func (r ClientRepository) Find(id string) (*models.Client, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	client, ok := r.store.clients[id]
+	if !ok {
+		return nil, services.NewApplicationErr(services.NoClientRecord, fmt.Errorf("クライアント: %s", id))
+	}
+	return &client, nil
+}
+
+func (r ClientRepository) Insert(client models.Client) (*models.Client, error) {
+	// 公開クライアントはシークレットを持たないため空のまま保存する
+	var hash string
+	if !client.IsPublic() {
+		encryptedSecret, err := models.NewEncryption(client.Secret())
+		if err != nil {
+			return nil, services.NewApplicationErr(services.TooLongPassword, err)
+		}
+		hash = encryptedSecret.Hash()
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.clients[client.ID()]; ok {
+		return nil, services.NewApplicationErr(services.DuplicateClient, fmt.Errorf("クライアント: %s", client.ID()))
+	}
+
+	stored := models.NewClient(
+		client.ID(), client.Name(), hash,
+		copyStrings(client.RedirectURIs()), copyStrings(client.GrantTypes()), copyStrings(client.Scopes()),
+	)
+	r.store.clients[client.ID()] = stored
+	return &stored, nil
+}
+
+func copyStrings(values []string) []string {
+	return append([]string{}, values...)
+}
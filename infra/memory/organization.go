@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"fmt"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewOrganizationRepository(store *Store) OrganizationRepository {
+	return OrganizationRepository{store: store}
+}
+
+type OrganizationRepository struct {
+	store *Store
+}
+
+func (r OrganizationRepository) Find(id string) (*models.Organization, error) {
+	return r.find(func(o models.Organization) bool { return o.ID() == id }, id)
+}
+
+func (r OrganizationRepository) FindBySlug(slug string) (*models.Organization, error) {
+	return r.find(func(o models.Organization) bool { return o.Slug() == slug }, slug)
+}
+
+// FindByHost hostを持たない組織はHostヘッダから特定しない
+func (r OrganizationRepository) FindByHost(host string) (*models.Organization, error) {
+	return r.find(func(o models.Organization) bool { return o.Host() != "" && o.Host() == host }, host)
+}
+
+func (r OrganizationRepository) Insert(organization models.Organization) (*models.Organization, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, o := range r.store.organizations {
+		if o.ID() == organization.ID() || o.Slug() == organization.Slug() ||
+			(o.Host() != "" && o.Host() == organization.Host()) {
+			return nil, services.NewApplicationErr(
+				services.DuplicateTenant, fmt.Errorf("組織: %s", organization.Slug()),
+			)
+		}
+	}
+
+	r.store.organizations[organization.ID()] = organization
+	return &organization, nil
+}
+
+func (r OrganizationRepository) find(match func(models.Organization) bool, value string) (*models.Organization, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, organization := range r.store.organizations {
+		if match(organization) {
+			return &organization, nil
+		}
+	}
+	return nil, services.NewApplicationErr(services.NoTenantRecord, fmt.Errorf("組織: %s", value))
+}
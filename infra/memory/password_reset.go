@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewPasswordResetTokenRepository(store *Store) PasswordResetTokenRepository {
+	return PasswordResetTokenRepository{store: store}
+}
+
+type PasswordResetTokenRepository struct {
+	store *Store
+}
+
+func (r PasswordResetTokenRepository) Insert(token models.PasswordResetToken) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.resetTokens[token.Hash()]; ok {
+		return services.NewApplicationErr(services.DuplicateToken, fmt.Errorf("トークン: %s", token.Hash()))
+	}
+	if _, ok := r.store.accounts[token.AccountID()]; !ok {
+		return services.NewApplicationErr(services.InternalServerErr, fmt.Errorf("ユーザー: %s", token.AccountID()))
+	}
+
+	r.store.resetTokens[token.Hash()] = token
+	return nil
+}
+
+// Consume トークンは1度しか使えないため、取得と同時に削除する
+func (r PasswordResetTokenRepository) Consume(hash string, now time.Time) (*models.PasswordResetToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	token, ok := r.store.resetTokens[hash]
+	if !ok || !now.Before(token.ExpiredAt()) {
+		return nil, services.NewApplicationErr(services.NoResetToken, fmt.Errorf("トークン: %s", hash))
+	}
+	delete(r.store.resetTokens, hash)
+	return &token, nil
+}
+
+func (r PasswordResetTokenRepository) DeleteByOwner(owner string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for hash, token := range r.store.resetTokens {
+		if token.AccountID() == owner {
+			delete(r.store.resetTokens, hash)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのトークンをlimit件まで削除する
+func (r PasswordResetTokenRepository) DeleteExpired(now time.Time, limit int) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for hash, token := range r.store.resetTokens {
+		if deleted >= int64(limit) {
+			break
+		}
+		if !now.Before(token.ExpiredAt()) {
+			delete(r.store.resetTokens, hash)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
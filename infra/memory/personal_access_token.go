@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// personalTokenRecord トークンはハッシュ値のみ保存する
+type personalTokenRecord struct {
+	token models.PersonalAccessToken
+	hash  string
+	seq   int
+}
+
+func NewPersonalAccessTokenRepository(store *Store) PersonalAccessTokenRepository {
+	return PersonalAccessTokenRepository{store: store}
+}
+
+type PersonalAccessTokenRepository struct {
+	store *Store
+}
+
+func (r PersonalAccessTokenRepository) Insert(
+	token models.PersonalAccessToken, hash string,
+) (*models.PersonalAccessToken, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for _, record := range r.store.personalTokens {
+		if record.token.ID() == token.ID() || record.hash == hash {
+			return nil, services.NewApplicationErr(services.DuplicateToken, fmt.Errorf("トークン: %s", token.ID()))
+		}
+	}
+	if _, ok := r.store.accounts[token.OwnerID()]; !ok {
+		return nil, services.NewApplicationErr(services.InternalServerErr, fmt.Errorf("ユーザー: %s", token.OwnerID()))
+	}
+
+	r.store.personalTokens[token.ID()] = personalTokenRecord{token: token, hash: hash, seq: r.store.next()}
+	return &token, nil
+}
+
+func (r PersonalAccessTokenRepository) FindByHash(hash string) (*models.PersonalAccessToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, record := range r.store.personalTokens {
+		if record.hash == hash {
+			return &record.token, nil
+		}
+	}
+	return nil, services.NewApplicationErr(services.NoTokenRecord, fmt.Errorf("ハッシュ: %s", hash))
+}
+
+// ListByOwner 有効期限内かつ未失効のトークンのみ取得する
+func (r PersonalAccessTokenRepository) ListByOwner(owner string, now time.Time) ([]models.PersonalAccessToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var records []personalTokenRecord
+	for _, record := range r.store.personalTokens {
+		t := record.token
+		if t.OwnerID() == owner && now.Before(t.ExpiredAt()) && !t.IsRevoked() {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]models.PersonalAccessToken, 0, len(records))
+	for _, record := range records {
+		results = append(results, record.token)
+	}
+	return results, nil
+}
+
+// Revoke 持ち主以外は失効できないよう、持ち主とIDの両方で絞り込む
+func (r PersonalAccessTokenRepository) Revoke(owner, id string, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	record, ok := r.store.personalTokens[id]
+	if !ok || record.token.OwnerID() != owner || record.token.IsRevoked() {
+		return services.NewApplicationErr(services.NoTokenRecord, fmt.Errorf("失効対象: %s", id))
+	}
+
+	t := record.token
+	record.token = models.NewPersonalAccessToken(
+		t.ID(), t.OrganizationID(), t.OwnerID(), t.Name(), t.Scope(), t.IssuedAt(), t.ExpiredAt(), now,
+	)
+	r.store.personalTokens[id] = record
+	return nil
+}
+
+// RevokeByOwner 持ち主の未失効のトークンを全て失効させる。対象が無くてもエラーにしない
+func (r PersonalAccessTokenRepository) RevokeByOwner(owner string, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, record := range r.store.personalTokens {
+		t := record.token
+		if t.OwnerID() != owner || t.IsRevoked() {
+			continue
+		}
+		record.token = models.NewPersonalAccessToken(
+			t.ID(), t.OrganizationID(), t.OwnerID(), t.Name(), t.Scope(), t.IssuedAt(), t.ExpiredAt(), now,
+		)
+		r.store.personalTokens[id] = record
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+type tokenRecord struct {
+	value     string
+	accountID string
+	familyID  string
+	clientID  string
+	scope     string
+	createdAt time.Time
+	expiredAt time.Time
+	revokedAt time.Time
+	seq       int
+}
+
+func (t tokenRecord) active(now time.Time) bool {
+	return now.Before(t.expiredAt) && t.revokedAt.IsZero()
+}
+
+func NewTokenRepository(store *Store) TokenRepository {
+	return TokenRepository{store: store}
+}
+
+type TokenRepository struct {
+	store *Store
+}
+
+func (r TokenRepository) Insert(refreshToken models.RefreshTokenInput) (string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.tokens[refreshToken.Value()]; ok {
+		return "", services.NewApplicationErr(services.DuplicateToken, fmt.Errorf("トークン: %s", refreshToken.Value()))
+	}
+	if _, ok := r.store.accounts[refreshToken.AccountID()]; !ok {
+		return "", services.NewApplicationErr(
+			services.InternalServerErr, fmt.Errorf("ユーザー: %s", refreshToken.AccountID()),
+		)
+	}
+
+	r.store.tokens[refreshToken.Value()] = tokenRecord{
+		value:     refreshToken.Value(),
+		accountID: refreshToken.AccountID(),
+		familyID:  refreshToken.FamilyID(),
+		clientID:  refreshToken.ClientID(),
+		scope:     refreshToken.Scope(),
+		createdAt: time.Now(),
+		expiredAt: refreshToken.ExpiredAt(),
+		seq:       r.store.next(),
+	}
+	return refreshToken.Value(), nil
+}
+
+func (r TokenRepository) Delete(refreshToken string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.tokens[refreshToken]; !ok {
+		return services.NewApplicationErr(services.NoTokenRecord, fmt.Errorf("削除対象: %s", refreshToken))
+	}
+	delete(r.store.tokens, refreshToken)
+	return nil
+}
+
+// FindOwner 有効期限内かつ未失効のトークンのみ取得する
+func (r TokenRepository) FindOwner(refreshToken string, now time.Time) (*models.RefreshToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	record, ok := r.store.tokens[refreshToken]
+	if !ok || !record.active(now) {
+		return nil, services.NewApplicationErr(services.NoTokenRecord, fmt.Errorf("トークン: %s", refreshToken))
+	}
+	return r.toModel(record), nil
+}
+
+// Find 更新済みのトークンの再利用を検知するため、失効済みや期限切れのトークンも取得する
+func (r TokenRepository) Find(refreshToken string) (*models.RefreshToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	record, ok := r.store.tokens[refreshToken]
+	if !ok {
+		return nil, services.NewApplicationErr(services.NoTokenRecord, fmt.Errorf("トークン: %s", refreshToken))
+	}
+	return r.toModel(record), nil
+}
+
+// Revoke 未失効のトークンのみ失効させる。同時に更新された場合は片方が失効済みエラーになる
+func (r TokenRepository) Revoke(refreshToken string, now time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	record, ok := r.store.tokens[refreshToken]
+	if !ok || !record.revokedAt.IsZero() {
+		return services.NewApplicationErr(services.RevokedToken, fmt.Errorf("失効対象: %s", refreshToken))
+	}
+	record.revokedAt = now
+	r.store.tokens[refreshToken] = record
+	return nil
+}
+
+// RevokeFamily 空のファミリーIDでは無関係なトークンまで失効させるため、何もしない
+func (r TokenRepository) RevokeFamily(familyID string, now time.Time) error {
+	if familyID == "" {
+		return nil
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for value, record := range r.store.tokens {
+		if record.familyID == familyID && record.revokedAt.IsZero() {
+			record.revokedAt = now
+			r.store.tokens[value] = record
+		}
+	}
+	return nil
+}
+
+// ListByOwner 有効期限内かつ未失効のトークンのみ取得する
+func (r TokenRepository) ListByOwner(owner string) ([]models.RefreshToken, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	now := time.Now()
+	var records []tokenRecord
+	for _, record := range r.store.tokens {
+		if record.accountID == owner && record.active(now) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]models.RefreshToken, 0, len(records))
+	for _, record := range records {
+		results = append(results, *r.toModel(record))
+	}
+	return results, nil
+}
+
+func (r TokenRepository) DeleteByOwner(owner string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for value, record := range r.store.tokens {
+		if record.accountID == owner {
+			delete(r.store.tokens, value)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのトークンをlimit件まで削除する
+// 失効済みでも期限内のトークンは再利用の検知に利用するため削除しない
+func (r TokenRepository) DeleteExpired(now time.Time, limit int) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for value, record := range r.store.tokens {
+		if deleted >= int64(limit) {
+			break
+		}
+		if !now.Before(record.expiredAt) {
+			delete(r.store.tokens, value)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// toModel ロックを取得した状態で呼び出す
+func (r TokenRepository) toModel(record tokenRecord) *models.RefreshToken {
+	account := r.store.accounts[record.accountID]
+	response := models.NewRefreshToken(
+		record.value, record.familyID, record.clientID, record.scope,
+		models.NewTokenOwner(account.OrganizationID(), account.ID(), account.Email()),
+		record.createdAt, record.expiredAt, record.revokedAt,
+	)
+	return &response
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"time"
+)
+
+func NewRevokedTokenRepository(store *Store) RevokedTokenRepository {
+	return RevokedTokenRepository{store: store}
+}
+
+type RevokedTokenRepository struct {
+	store *Store
+}
+
+// Insert 既に失効済みの場合は何もしない
+func (r RevokedTokenRepository) Insert(tokenID string, expiredAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.revoked[tokenID]; !ok {
+		r.store.revoked[tokenID] = expiredAt
+	}
+	return nil
+}
+
+func (r RevokedTokenRepository) Exists(tokenID string) (bool, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	_, ok := r.store.revoked[tokenID]
+	return ok, nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+
+	"auth-test/services"
+)
+
+func NewRoleRepository(store *Store) RoleRepository {
+	return RoleRepository{store: store}
+}
+
+type RoleRepository struct {
+	store *Store
+}
+
+func (r RoleRepository) FindByAccount(accountID string) ([]string, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var roles []string
+	for role := range r.store.userRoles[accountID] {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// Assign 既に割り当て済みの場合は何もしない
+func (r RoleRepository) Assign(accountID, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if !r.store.roles[name] {
+		return services.NewApplicationErr(services.NoRoleRecord, fmt.Errorf("role: %s", name))
+	}
+	if r.store.userRoles[accountID] == nil {
+		r.store.userRoles[accountID] = map[string]bool{}
+	}
+	r.store.userRoles[accountID][name] = true
+	return nil
+}
+
+func (r RoleRepository) Unassign(accountID, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if !r.store.roles[name] {
+		return services.NewApplicationErr(services.NoRoleRecord, fmt.Errorf("role: %s", name))
+	}
+	delete(r.store.userRoles[accountID], name)
+	return nil
+}
@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"auth-test/models"
+)
+
+func NewSecurityEventRepository(store *Store) SecurityEventRepository {
+	return SecurityEventRepository{store: store}
+}
+
+type SecurityEventRepository struct {
+	store *Store
+}
+
+func (r SecurityEventRepository) Record(event models.SecurityEvent) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.events = append(r.store.events, event)
+	return nil
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"auth-test/models"
+)
+
+// signingKeyRecord 登録日時が同じ鍵も登録した順に並べるため連番を持つ
+type signingKeyRecord struct {
+	key models.SigningKey
+	seq int
+}
+
+func NewSigningKeyRepository(store *Store) SigningKeyRepository {
+	return SigningKeyRepository{store: store}
+}
+
+type SigningKeyRepository struct {
+	store *Store
+}
+
+func (r SigningKeyRepository) Rotate(key models.SigningKey, retiredAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for id, record := range r.store.signingKeys {
+		k := record.key
+		if !k.RetiredAt().IsZero() {
+			continue
+		}
+		record.key = models.NewSigningKey(k.ID(), k.Algorithm(), k.Material(), k.CreatedAt(), retiredAt)
+		r.store.signingKeys[id] = record
+	}
+
+	r.store.signingKeys[key.ID()] = signingKeyRecord{
+		key: models.NewSigningKey(key.ID(), key.Algorithm(), key.Material(), key.CreatedAt(), time.Time{}),
+		seq: r.store.next(),
+	}
+	return nil
+}
+
+// List 署名に使う鍵が最後になるよう、退役した鍵を先に登録した順に並べる
+func (r SigningKeyRepository) List() ([]models.SigningKey, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	records := make([]signingKeyRecord, 0, len(r.store.signingKeys))
+	for _, record := range r.store.signingKeys {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		iActive, jActive := records[i].key.RetiredAt().IsZero(), records[j].key.RetiredAt().IsZero()
+		if iActive != jActive {
+			return jActive
+		}
+		return records[i].seq < records[j].seq
+	})
+
+	results := make([]models.SigningKey, 0, len(records))
+	for _, record := range records {
+		results = append(results, record.key)
+	}
+	return results, nil
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"auth-test/models"
+)
+
+// Store 全てのリポジトリが共有するデータ
+// MySQLの外部キーによる削除の連鎖を再現するため、全てのテーブルを1つのロックで保護する
+type Store struct {
+	mu             sync.RWMutex
+	seq            int
+	accounts       map[string]models.UserAccount
+	sessions       map[string]sessionRecord
+	tokens         map[string]tokenRecord
+	revoked        map[string]time.Time
+	codes          map[string]models.AuthorizationCode
+	clients        map[string]models.Client
+	organizations  map[string]models.Organization
+	roles          map[string]bool
+	userRoles      map[string]map[string]bool
+	personalTokens map[string]personalTokenRecord
+	resetTokens    map[string]models.PasswordResetToken
+	signingKeys    map[string]signingKeyRecord
+	events         []models.SecurityEvent
+}
+
+// NewStore マイグレーションと同じく既定の組織と定義済みのロールを登録した状態で作成する
+func NewStore() *Store {
+	s := &Store{
+		accounts:       map[string]models.UserAccount{},
+		sessions:       map[string]sessionRecord{},
+		tokens:         map[string]tokenRecord{},
+		revoked:        map[string]time.Time{},
+		codes:          map[string]models.AuthorizationCode{},
+		clients:        map[string]models.Client{},
+		organizations:  map[string]models.Organization{},
+		roles:          map[string]bool{},
+		userRoles:      map[string]map[string]bool{},
+		personalTokens: map[string]personalTokenRecord{},
+		resetTokens:    map[string]models.PasswordResetToken{},
+		signingKeys:    map[string]signingKeyRecord{},
+	}
+
+	s.organizations[models.DefaultOrganizationID] = models.NewOrganization(
+		models.DefaultOrganizationID, "default", "default", "",
+	)
+	for _, role := range models.DefinedRoles {
+		s.roles[role] = true
+	}
+	return s
+}
+
+// next 作成日時が同じレコードも登録した順に並べるための連番を返す
+func (s *Store) next() int {
+	s.seq++
+	return s.seq
+}
+
+// cascade ユーザに外部キーで紐づくレコードを削除する。ロックを取得した状態で呼び出す
+func (s *Store) cascade(accountID string) {
+	for id, record := range s.sessions {
+		if record.session.Owner() == accountID {
+			delete(s.sessions, id)
+		}
+	}
+	for id, record := range s.tokens {
+		if record.accountID == accountID {
+			delete(s.tokens, id)
+		}
+	}
+	for id, code := range s.codes {
+		if code.AccountID() == accountID {
+			delete(s.codes, id)
+		}
+	}
+	for id, record := range s.personalTokens {
+		if record.token.OwnerID() == accountID {
+			delete(s.personalTokens, id)
+		}
+	}
+	for hash, token := range s.resetTokens {
+		if token.AccountID() == accountID {
+			delete(s.resetTokens, hash)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package memory_test
+
+import (
+	"testing"
+
+	"auth-test/infra/memory"
+	"auth-test/infra/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Accessors {
+		store := memory.NewStore()
+		return storetest.Accessors{
+			UserAccount:   memory.NewUserAccountRepository(store),
+			UserSession:   memory.NewUserSessionRepo(store),
+			Token:         memory.NewTokenRepository(store),
+			Revoked:       memory.NewRevokedTokenRepository(store),
+			Code:          memory.NewAuthorizationCodeRepository(store),
+			Client:        memory.NewClientRepository(store),
+			Organization:  memory.NewOrganizationRepository(store),
+			Role:          memory.NewRoleRepository(store),
+			PersonalToken: memory.NewPersonalAccessTokenRepository(store),
+			Event:         memory.NewSecurityEventRepository(store),
+			ResetToken:    memory.NewPasswordResetTokenRepository(store),
+			SigningKey:    memory.NewSigningKeyRepository(store),
+			Locker:        memory.NewLocker(),
+		}
+	})
+}
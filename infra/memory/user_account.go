@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func NewUserAccountRepository(store *Store) *UserAccountRepository {
+	return &UserAccountRepository{store: store}
+}
+
+type UserAccountRepository struct {
+	store *Store
+}
+
+func (r *UserAccountRepository) Find(id string) (*models.UserAccount, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	account, ok := r.store.accounts[id]
+	if !ok {
+		return nil, services.NewApplicationErr(services.NoUserRecord, fmt.Errorf("ID: %s", id))
+	}
+	return &account, nil
+}
+
+func (r *UserAccountRepository) FindByEmail(orgID, email string) (*models.UserAccount, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	account, ok := r.findByEmail(orgID, email)
+	if !ok {
+		return nil, services.NewApplicationErr(services.NoUserEmail, errors.New(email))
+	}
+	return &account, nil
+}
+
+func (r *UserAccountRepository) List(orgID string) ([]models.UserAccount, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var results []models.UserAccount
+	for _, account := range r.store.accounts {
+		if account.OrganizationID() == orgID {
+			results = append(results, account)
+		}
+	}
+	return results, nil
+}
+
+func (r *UserAccountRepository) Insert(orgID, id, email, name, password string) (*models.UserAccount, error) {
+	encryptedPass, err := models.NewEncryption(password)
+	if err != nil {
+		return nil, services.NewApplicationErr(services.TooLongPassword, err)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// MySQLでは主キーと組織ごとのemailの一意制約違反はいずれも同じエラーになる
+	if _, ok := r.store.accounts[id]; ok {
+		return nil, services.NewApplicationErr(services.DuplicateUserEmail, fmt.Errorf("ID: %s", id))
+	}
+	if _, ok := r.findByEmail(orgID, email); ok {
+		return nil, services.NewApplicationErr(services.DuplicateUserEmail, errors.New(email))
+	}
+
+	account := models.NewStoredUserAccount(
+		orgID, id, email, name, encryptedPass.Hash(), false, false, 0, time.Time{}, time.Now(),
+	)
+	r.store.accounts[id] = account
+	return &account, nil
+}
+
+// Update メールアドレスと名前のみ更新する。パスワードはUpdatePasswordで更新する
+func (r *UserAccountRepository) Update(account models.UserAccount) (*models.UserAccount, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	current, ok := r.store.accounts[account.ID()]
+	if !ok {
+		return nil, services.NewApplicationErr(services.NoUserRecord, fmt.Errorf("ID: %s", account.ID()))
+	}
+	if other, ok := r.findByEmail(current.OrganizationID(), account.Email()); ok && other.ID() != current.ID() {
+		return nil, services.NewApplicationErr(services.DuplicateUserEmail, errors.New(account.Email()))
+	}
+
+	// メールアドレスを変更した場合は確認し直すまで未確認とする
+	updated := models.NewStoredUserAccount(
+		current.OrganizationID(), current.ID(), account.Email(), account.Name(), current.Password(),
+		current.EmailVerified() && current.Email() == account.Email(), current.ResetRequired(),
+		current.TokenGeneration(), current.DisabledAt(), time.Now(),
+	)
+	r.store.accounts[current.ID()] = updated
+	return &updated, nil
+}
+
+// UpdatePassword パスワードを設定し直したため、再設定の要求は解除する
+func (r *UserAccountRepository) UpdatePassword(id, password string) error {
+	encryptedPass, err := models.NewEncryption(password)
+	if err != nil {
+		return services.NewApplicationErr(services.TooLongPassword, err)
+	}
+
+	return r.update(id, func(a models.UserAccount) models.UserAccount {
+		return models.NewStoredUserAccount(
+			a.OrganizationID(), a.ID(), a.Email(), a.Name(), encryptedPass.Hash(), a.EmailVerified(), false,
+			a.TokenGeneration(), a.DisabledAt(), a.UpdatedAt(),
+		)
+	})
+}
+
+func (r *UserAccountRepository) Delete(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return services.NewApplicationErr(services.InvalidUUIDFormat, err)
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.accounts[id]; !ok {
+		return services.NewApplicationErr(services.NoUserRecord, fmt.Errorf("削除対象ID: %s", id))
+	}
+	delete(r.store.accounts, id)
+	r.store.cascade(id)
+	return nil
+}
+
+func (r *UserAccountRepository) RequirePasswordReset(id string) error {
+	return r.update(id, func(a models.UserAccount) models.UserAccount {
+		return models.NewStoredUserAccount(
+			a.OrganizationID(), a.ID(), a.Email(), a.Name(), a.Password(), a.EmailVerified(), true,
+			a.TokenGeneration(), a.DisabledAt(), a.UpdatedAt(),
+		)
+	})
+}
+
+func (r *UserAccountRepository) Disable(id string, now time.Time) error {
+	return r.update(id, func(a models.UserAccount) models.UserAccount {
+		return models.NewStoredUserAccount(
+			a.OrganizationID(), a.ID(), a.Email(), a.Name(), a.Password(), a.EmailVerified(), a.ResetRequired(),
+			a.TokenGeneration(), now, a.UpdatedAt(),
+		)
+	})
+}
+
+func (r *UserAccountRepository) Enable(id string) error {
+	return r.update(id, func(a models.UserAccount) models.UserAccount {
+		return models.NewStoredUserAccount(
+			a.OrganizationID(), a.ID(), a.Email(), a.Name(), a.Password(), a.EmailVerified(), a.ResetRequired(),
+			a.TokenGeneration(), time.Time{}, a.UpdatedAt(),
+		)
+	})
+}
+
+func (r *UserAccountRepository) IncrementTokenGeneration(id string) error {
+	return r.update(id, func(a models.UserAccount) models.UserAccount {
+		return models.NewStoredUserAccount(
+			a.OrganizationID(), a.ID(), a.Email(), a.Name(), a.Password(), a.EmailVerified(), a.ResetRequired(),
+			a.TokenGeneration()+1, a.DisabledAt(), a.UpdatedAt(),
+		)
+	})
+}
+
+func (r *UserAccountRepository) VerifyEmail(id string) error {
+	return r.update(id, func(a models.UserAccount) models.UserAccount {
+		return models.NewStoredUserAccount(
+			a.OrganizationID(), a.ID(), a.Email(), a.Name(), a.Password(), true, a.ResetRequired(),
+			a.TokenGeneration(), a.DisabledAt(), a.UpdatedAt(),
+		)
+	})
+}
+
+func (r *UserAccountRepository) update(id string, apply func(models.UserAccount) models.UserAccount) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	account, ok := r.store.accounts[id]
+	if !ok {
+		return services.NewApplicationErr(services.NoUserRecord, fmt.Errorf("更新対象ID: %s", id))
+	}
+	r.store.accounts[id] = apply(account)
+	return nil
+}
+
+// findByEmail ロックを取得した状態で呼び出す
+func (r *UserAccountRepository) findByEmail(orgID, email string) (models.UserAccount, bool) {
+	for _, account := range r.store.accounts {
+		if account.OrganizationID() == orgID && account.Email() == email {
+			return account, true
+		}
+	}
+	return models.UserAccount{}, false
+}
@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// userAgentLength UserAgentとして保存する最大の長さ
+const userAgentLength = 255
+
+type sessionRecord struct {
+	session models.Session
+	seq     int
+}
+
+func NewUserSessionRepo(store *Store) UserSessionRepository {
+	return UserSessionRepository{store: store}
+}
+
+type UserSessionRepository struct {
+	store *Store
+}
+
+func (r UserSessionRepository) Register(session models.Session) (string, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.sessions[session.Token()]; ok {
+		return "", services.NewApplicationErr(services.DuplicateUserEmail, fmt.Errorf("セッション: %s", session.Token()))
+	}
+	if _, ok := r.store.accounts[session.Owner()]; !ok {
+		return "", services.NewApplicationErr(services.InternalServerErr, fmt.Errorf("ユーザー: %s", session.Owner()))
+	}
+
+	now := time.Now()
+	r.store.sessions[session.Token()] = sessionRecord{
+		session: models.NewStoredSession(
+			session.Owner(), session.Token(), session.IPAddress(), truncate(session.UserAgent(), userAgentLength),
+			now, now, session.ExpiredAt(), session.AbsoluteExpiredAt(),
+		),
+		seq: r.store.next(),
+	}
+	return session.Token(), nil
+}
+
+func (r UserSessionRepository) Verify(token string) error {
+	_, err := r.FindOwner(token, time.Now())
+	return err
+}
+
+// FindOwner 有効期限内のセッションのみ取得する
+func (r UserSessionRepository) FindOwner(token string, now time.Time) (*models.Session, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	record, ok := r.store.sessions[token]
+	if !ok || !active(record.session, now) {
+		return nil, services.NewApplicationErr(services.NoSessionRecord, fmt.Errorf("セッション: %s", token))
+	}
+	return &record.session, nil
+}
+
+// Extend 期限を短くする更新は行わない
+func (r UserSessionRepository) Extend(token string, now, expiredAt time.Time) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	record, ok := r.store.sessions[token]
+	if !ok || !record.session.ExpiredAt().Before(expiredAt) {
+		return nil
+	}
+
+	s := record.session
+	record.session = models.NewStoredSession(
+		s.Owner(), s.Token(), s.IPAddress(), s.UserAgent(), s.CreatedAt(), now, expiredAt, s.AbsoluteExpiredAt(),
+	)
+	r.store.sessions[token] = record
+	return nil
+}
+
+func (r UserSessionRepository) Delete(owner, token string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	record, ok := r.store.sessions[token]
+	if !ok || record.session.Owner() != owner {
+		return services.NewApplicationErr(
+			services.NoSessionRecord, fmt.Errorf("ユーザー: %s, 削除対象セッション: %s", owner, token))
+	}
+	delete(r.store.sessions, token)
+	return nil
+}
+
+func (r UserSessionRepository) ListByOwner(owner string) ([]models.Session, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	now := time.Now()
+	var records []sessionRecord
+	for _, record := range r.store.sessions {
+		if record.session.Owner() == owner && active(record.session, now) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	results := make([]models.Session, 0, len(records))
+	for _, record := range records {
+		results = append(results, record.session)
+	}
+	return results, nil
+}
+
+func (r UserSessionRepository) DeleteByOwner(owner string) error {
+	return r.DeleteOthers(owner, "")
+}
+
+// DeleteOthers keepで指定したセッション以外を削除する
+func (r UserSessionRepository) DeleteOthers(owner, keep string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for token, record := range r.store.sessions {
+		if record.session.Owner() == owner && token != keep {
+			delete(r.store.sessions, token)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired 期限切れのセッションをlimit件まで削除する
+func (r UserSessionRepository) DeleteExpired(now time.Time, limit int) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted int64
+	for token, record := range r.store.sessions {
+		if deleted >= int64(limit) {
+			break
+		}
+		if !active(record.session, now) {
+			delete(r.store.sessions, token)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func active(session models.Session, now time.Time) bool {
+	return now.Before(session.ExpiredAt()) && now.Before(session.AbsoluteExpiredAt())
+}
+
+func truncate(value string, length int) string {
+	runes := []rune(value)
+	if len(runes) <= length {
+		return value
+	}
+	return string(runes[:length])
+}
@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"auth-test/models"
+)
+
+// NewEngine pathが空の場合は DefaultDocument のルールで評価する
+func NewEngine(path string) (*Engine, error) {
+	engine := &Engine{path: path, rules: DefaultDocument().Rules}
+	if path == "" {
+		return engine, nil
+	}
+
+	if err := engine.Reload(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+// Engine (主体, 操作, 操作対象)をルールで評価する
+// denyに一致するルールがあれば拒否し、allowに一致するルールが無い場合も拒否する
+type Engine struct {
+	path    string
+	mu      sync.RWMutex
+	rules   []Rule
+	modTime time.Time
+}
+
+func (e *Engine) Evaluate(request models.PolicyRequest) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	allowed := false
+	for _, rule := range e.rules {
+		if !rule.matches(request) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
+
+// Reload ポリシーファイルを読み込み直す。不正な内容の場合は読み込み済みのルールを維持する
+func (e *Engine) Reload() error {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return err
+	}
+
+	rules, err := load(e.path)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+	e.modTime = info.ModTime()
+	return nil
+}
+
+// Watch ポリシーファイルの更新日時をintervalごとに確認し、変更されていれば読み込み直す
+func (e *Engine) Watch(interval time.Duration, stop <-chan struct{}) {
+	if e.path == "" || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !e.modified() {
+				continue
+			}
+			if err := e.Reload(); err != nil {
+				log.Printf("ポリシーの再読み込みに失敗。: %s \n", err.Error())
+			}
+		}
+	}
+}
+
+func (e *Engine) modified() bool {
+	info, err := os.Stat(e.path)
+	if err != nil {
+		return false
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return !info.ModTime().Equal(e.modTime)
+}
+
+func load(path string) ([]Rule, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document Document
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(body, &document)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &document)
+	default:
+		return nil, fmt.Errorf("未対応のポリシーファイルです: %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, rule := range document.Rules {
+		if rule.Effect == "" {
+			document.Rules[i].Effect = EffectAllow
+		}
+		if err := document.Rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("%d番目のルールが不正です: %w", i+1, err)
+		}
+	}
+	return document.Rules, nil
+}
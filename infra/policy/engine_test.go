@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"auth-test/models"
+)
+
+const (
+	self  = "11111111-1111-1111-1111-111111111111"
+	other = "22222222-2222-2222-2222-222222222222"
+)
+
+// customDocument サポート担当者に閲覧のみ許可し、ユーザの削除は管理者を含め全員に拒否する
+const customDocument = `
+rules:
+  - roles: [admin]
+    actions: ["*"]
+    resources: ["*"]
+  - roles: [support]
+    actions: [read, list]
+    resources: [user, session]
+  - owner: true
+    actions: ["*"]
+    resources: [user]
+  - effect: deny
+    actions: [delete]
+    resources: [user]
+`
+
+func principal(roles ...string) models.Principal {
+	return models.NewPrincipal(models.DefaultOrganizationID, self, "user@example.com", []string{}, "", roles)
+}
+
+func newCustomEngine(t *testing.T) *Engine {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(customDocument), 0o600); err != nil {
+		t.Fatalf("ポリシーファイルの作成に失敗: %v", err)
+	}
+	engine, err := NewEngine(path)
+	if err != nil {
+		t.Fatalf("ポリシーの読み込みに失敗: %v", err)
+	}
+	return engine
+}
+
+func TestEvaluate(t *testing.T) {
+	defaultEngine, err := NewEngine("")
+	if err != nil {
+		t.Fatalf("ポリシーの初期化に失敗: %v", err)
+	}
+	customEngine := newCustomEngine(t)
+
+	tests := []struct {
+		name      string
+		engine    *Engine
+		principal models.Principal
+		action    string
+		resource  string
+		owner     string
+		want      bool
+	}{
+		{
+			name: "既定: 管理者は他のユーザを削除できる", engine: defaultEngine, principal: principal(models.RoleAdmin),
+			action: models.ActionDelete, resource: models.ResourceUser, owner: other, want: true,
+		},
+		{
+			name: "既定: 本人は自身の情報を更新できる", engine: defaultEngine, principal: principal(),
+			action: models.ActionUpdate, resource: models.ResourceUser, owner: self, want: true,
+		},
+		{
+			name: "既定: 本人は自身のセッションを一覧できる", engine: defaultEngine, principal: principal(),
+			action: models.ActionList, resource: models.ResourceSession, owner: self, want: true,
+		},
+		{
+			name: "既定: 本人は自身のアクセストークンを発行できる", engine: defaultEngine, principal: principal(),
+			action: models.ActionCreate, resource: models.ResourcePAT, owner: self, want: true,
+		},
+		{
+			name: "既定: 他のユーザの情報は参照できない", engine: defaultEngine, principal: principal(),
+			action: models.ActionRead, resource: models.ResourceUser, owner: other, want: false,
+		},
+		{
+			name: "既定: 一致するルールが無い操作は拒否する", engine: defaultEngine, principal: principal(),
+			action: models.ActionCreate, resource: models.ResourceClient, want: false,
+		},
+		{
+			name: "既定: サポート担当者にも許可しない", engine: defaultEngine, principal: principal(models.RoleSupport),
+			action: models.ActionRead, resource: models.ResourceUser, owner: other, want: false,
+		},
+		{
+			name: "持ち主が無い操作対象は本人として扱わない", engine: defaultEngine, principal: principal(),
+			action: models.ActionRead, resource: models.ResourceUser, owner: "", want: false,
+		},
+		{
+			name: "ファイル: サポート担当者は他のユーザを参照できる", engine: customEngine,
+			principal: principal(models.RoleSupport), action: models.ActionRead, resource: models.ResourceUser,
+			owner: other, want: true,
+		},
+		{
+			name: "ファイル: サポート担当者は他のユーザを更新できない", engine: customEngine,
+			principal: principal(models.RoleSupport), action: models.ActionUpdate, resource: models.ResourceUser,
+			owner: other, want: false,
+		},
+		{
+			name: "ファイル: 拒否するルールは本人への許可より優先する", engine: customEngine, principal: principal(),
+			action: models.ActionDelete, resource: models.ResourceUser, owner: self, want: false,
+		},
+		{
+			name: "ファイル: 拒否するルールは管理者への許可より優先する", engine: customEngine,
+			principal: principal(models.RoleAdmin), action: models.ActionDelete, resource: models.ResourceUser,
+			owner: other, want: false,
+		},
+		{
+			name: "ファイル: ワイルドカードは全ての操作対象に一致する", engine: customEngine,
+			principal: principal(models.RoleAdmin), action: models.ActionCreate, resource: models.ResourceSigningKey,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := models.NewPolicyRequest(tt.principal, tt.action, tt.resource, tt.owner)
+			if got := tt.engine.Evaluate(request); got != tt.want {
+				t.Fatalf("評価結果が一致しません: want %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewEngineRejectsInvalidDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		body     string
+	}{
+		{name: "未知のeffect", filename: "policy.yaml", body: "rules:\n  - effect: maybe\n    actions: [read]\n    resources: [user]\n"},
+		{name: "actionsの指定が無い", filename: "policy.yaml", body: "rules:\n  - resources: [user]\n"},
+		{name: "未対応の拡張子", filename: "policy.txt", body: "rules: []\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.filename)
+			if err := os.WriteFile(path, []byte(tt.body), 0o600); err != nil {
+				t.Fatalf("ポリシーファイルの作成に失敗: %v", err)
+			}
+			if _, err := NewEngine(path); err == nil {
+				t.Fatalf("不正なポリシーを読み込めてしまいます")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"fmt"
+
+	"auth-test/models"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+
+	wildcard = "*"
+)
+
+// Document ポリシーファイルの内容
+type Document struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// Rule rolesのいずれかを持つ主体、またはownerの場合は持ち主自身に対して、actionsとresourcesの組み合わせを許可・拒否する
+// rolesとownerの両方を省略した場合は全ての主体が対象となる
+type Rule struct {
+	Effect    string   `yaml:"effect" json:"effect"`
+	Roles     []string `yaml:"roles" json:"roles"`
+	Owner     bool     `yaml:"owner" json:"owner"`
+	Actions   []string `yaml:"actions" json:"actions"`
+	Resources []string `yaml:"resources" json:"resources"`
+}
+
+func (r Rule) validate() error {
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("effectはallowかdenyを指定してください: %s", r.Effect)
+	}
+	if len(r.Actions) == 0 || len(r.Resources) == 0 {
+		return fmt.Errorf("actionsとresourcesは必須です")
+	}
+	return nil
+}
+
+func (r Rule) matches(request models.PolicyRequest) bool {
+	if !match(r.Actions, request.Action()) || !match(r.Resources, request.Resource()) {
+		return false
+	}
+
+	if len(r.Roles) == 0 && !r.Owner {
+		return true
+	}
+	if r.Owner && request.IsOwner() {
+		return true
+	}
+	for _, role := range r.Roles {
+		if request.Principal().HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if p == wildcard || p == value {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultDocument ポリシーファイルを指定しない場合のルール
+// 持ち主本人と管理者のみが操作できる
+func DefaultDocument() Document {
+	return Document{Rules: []Rule{
+		{Effect: EffectAllow, Roles: []string{models.RoleAdmin}, Actions: []string{wildcard}, Resources: []string{wildcard}},
+		{
+			Effect:    EffectAllow,
+			Owner:     true,
+			Actions:   []string{models.ActionRead, models.ActionUpdate, models.ActionDelete},
+			Resources: []string{models.ResourceUser, models.ResourceSession},
+		},
+		{Effect: EffectAllow, Owner: true, Actions: []string{models.ActionList}, Resources: []string{models.ResourceSession}},
+		{
+			Effect:    EffectAllow,
+			Owner:     true,
+			Actions:   []string{models.ActionList, models.ActionCreate, models.ActionDelete},
+			Resources: []string{models.ResourcePAT},
+		},
+	}}
+}
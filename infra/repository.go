@@ -0,0 +1,90 @@
+package infra
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"auth-test/infra/configuration"
+	"auth-test/infra/db"
+	"auth-test/infra/memory"
+	"auth-test/models"
+)
+
+const (
+	StoreMySQL  = "mysql"
+	StoreMemory = "memory"
+)
+
+// repositories STOREで指定した保存先のリポジトリ
+type repositories struct {
+	userAccount   models.UserAccountAccessor
+	userSession   models.UserSessionAccessor
+	token         models.TokenAccessor
+	revoked       models.RevokedTokenAccessor
+	code          models.AuthorizationCodeAccessor
+	client        models.ClientAccessor
+	organization  models.OrganizationAccessor
+	role          models.RoleAccessor
+	personalToken models.PersonalAccessTokenAccessor
+	event         models.SecurityEventRecorder
+	resetToken    models.PasswordResetTokenAccessor
+	signingKey    models.SigningKeyAccessor
+	locker        models.Locker
+}
+
+func newRepositories(env configuration.Environment) (*repositories, error) {
+	switch env.Store {
+	case StoreMySQL:
+		dsn := fmt.Sprintf(
+			"%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
+			env.User, env.Password, env.Host, env.Port, env.Name,
+		)
+		dbClient, err := db.NewClient(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return newMySQLRepositories(*dbClient), nil
+	case StoreMemory:
+		return newMemoryRepositories(memory.NewStore()), nil
+	default:
+		return nil, fmt.Errorf("未対応の保存先です: %s", env.Store)
+	}
+}
+
+func newMySQLRepositories(dbClient gorm.DB) *repositories {
+	return &repositories{
+		userAccount:   db.NewUserAccountRepository(dbClient),
+		userSession:   db.NewUserSessionRepo(dbClient),
+		token:         db.NewTokenRepository(dbClient),
+		revoked:       db.NewRevokedTokenRepository(dbClient),
+		code:          db.NewAuthorizationCodeRepository(dbClient),
+		client:        db.NewClientRepository(dbClient),
+		organization:  db.NewOrganizationRepository(dbClient),
+		role:          db.NewRoleRepository(dbClient),
+		personalToken: db.NewPersonalAccessTokenRepository(dbClient),
+		event:         db.NewSecurityEventRepository(dbClient),
+		resetToken:    db.NewPasswordResetTokenRepository(dbClient),
+		signingKey:    db.NewSigningKeyRepository(dbClient),
+		locker:        db.NewLocker(dbClient),
+	}
+}
+
+// newMemoryRepositories データはプロセス内にのみ保持するため、再起動すると失われる
+func newMemoryRepositories(store *memory.Store) *repositories {
+	return &repositories{
+		userAccount:   memory.NewUserAccountRepository(store),
+		userSession:   memory.NewUserSessionRepo(store),
+		token:         memory.NewTokenRepository(store),
+		revoked:       memory.NewRevokedTokenRepository(store),
+		code:          memory.NewAuthorizationCodeRepository(store),
+		client:        memory.NewClientRepository(store),
+		organization:  memory.NewOrganizationRepository(store),
+		role:          memory.NewRoleRepository(store),
+		personalToken: memory.NewPersonalAccessTokenRepository(store),
+		event:         memory.NewSecurityEventRepository(store),
+		resetToken:    memory.NewPasswordResetTokenRepository(store),
+		signingKey:    memory.NewSigningKeyRepository(store),
+		locker:        memory.NewLocker(),
+	}
+}
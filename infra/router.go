@@ -1,7 +1,8 @@
 package infra
 
 import (
-	"fmt"
+	"expvar"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -9,13 +10,13 @@ import (
 	"github.com/kelseyhightower/envconfig"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
-	"gorm.io/gorm"
 
 	"auth-test/docs"
 	"auth-test/infra/auth"
 	"auth-test/infra/configuration"
 	"auth-test/infra/controller"
-	"auth-test/infra/db"
+	"auth-test/infra/policy"
+	"auth-test/models"
 	"auth-test/services"
 )
 
@@ -30,15 +31,17 @@ func Run() error {
 		return err
 	}
 
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
-		env.User, env.Password, env.Host, env.Port, env.Name,
+	repos, err := newRepositories(env)
+	if err != nil {
+		return err
+	}
+	gc, err := services.NewGarbageCollector(
+		repos.userSession, repos.token, repos.resetToken, repos.locker, env.GCBatchSize,
 	)
-	dbClient, err := db.NewClient(dsn)
 	if err != nil {
 		return err
-
 	}
+	go collectGarbage(gc, env.GCInterval)
 
 	validate := validator.New()
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
@@ -47,68 +50,251 @@ func Run() error {
 		}
 	}
 
-	router, err := setUpRouter(env, *dbClient, *validate)
+	router, err := setUpRouter(env, *repos, *validate)
 	if err != nil {
 		return err
 	}
 
-	return router.Run("0.0.0.0:8080")
+	return http.ListenAndServe("0.0.0.0:8080", router)
 }
 
-func setUpRouter(env configuration.Environment, dbClient gorm.DB, validate validator.Validate) (*gin.Engine, error) {
-	userAccountRepo := db.NewUserAccountRepository(dbClient)
-	userAccountSvc := services.NewUserAccount(userAccountRepo)
-	userAccountController := controller.NewUserAccountHandler(userAccountSvc, validate)
+func setUpRouter(env configuration.Environment, repos repositories, validate validator.Validate) (http.Handler, error) {
+	mailer, err := newMailer(env)
+	if err != nil {
+		return nil, err
+	}
+	userAccountRepo := repos.userAccount
+	userAccountSvc := services.NewUserAccount(
+		userAccountRepo, repos.userSession, repos.token, repos.personalToken, repos.event,
+	)
+	emailVerifier := services.NewEmailVerifier(
+		userAccountRepo, mailer, env.EncryptSecret, env.VerifyURL, env.VerifyExpiration,
+	)
+	userAccountController := controller.NewUserAccountHandler(userAccountSvc, emailVerifier, validate)
+	emailVerificationController := controller.NewEmailVerificationHandler(emailVerifier)
+	signInPolicy := services.NewSignInPolicy(env.RequireVerified)
 
-	tokenAuth := auth.NewTokenAuthorization(env.EncryptSecret)
-	tokenRepo := db.NewTokenRepository(dbClient)
+	tokenAuth, err := auth.NewAuthorizer(
+		env.Issuer, env.SigningAlgorithm, env.EncryptSecret, env.SigningKeyPath, env.AccessExpiration, repos.signingKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	go tokenAuth.Watch(env.KeyInterval, nil)
+	tokenRepo := repos.token
+	revokedRepo := repos.revoked
+	codeRepo := repos.code
+	clientRepo := repos.client
+	eventRepo := repos.event
+	roleRepo := repos.role
+	personalTokenRepo := repos.personalToken
+	sessionLimit, err := services.NewSessionLimit(env.SessionLimit, env.LimitPolicy, repos.locker)
+	if err != nil {
+		return nil, err
+	}
 	tokenAuthSvc := services.NewTokenAuthorization(
-		tokenAuth, tokenRepo, userAccountRepo, env.RefreshExpiration, env.AccessExpiration,
+		tokenAuth, tokenRepo, revokedRepo, codeRepo, clientRepo, userAccountRepo, roleRepo, personalTokenRepo, eventRepo,
+		sessionLimit, signInPolicy, env.RefreshExpiration, env.AccessExpiration, env.CodeExpiration,
 	)
 	tokenAuthController := controller.NewTokenHandler(tokenAuthSvc)
+	clientController := controller.NewClientHandler(services.NewClientRegistry(clientRepo))
+	personalTokenController := controller.NewPersonalAccessTokenHandler(
+		services.NewPersonalAccessTokens(personalTokenRepo, env.PATMaxExpiration),
+	)
 
-	userSessionRepo := db.NewUserSessionRepo(dbClient)
-	userSessionSvc := services.NewSessionAuthorization(userAccountRepo, userSessionRepo, env.SessionExpiration)
-	userSessionController := controller.NewSessionAuth(userSessionSvc)
+	userSessionRepo := repos.userSession
+	userSessionSvc := services.NewSessionAuthorization(
+		userAccountRepo, userSessionRepo, roleRepo, eventRepo, sessionLimit, signInPolicy,
+		env.SessionExpiration, env.SessionIdle, env.SessionTouch,
+	)
+	sessionCookie := controller.NewSessionCookie(
+		env.SessionCookie, env.CookieName, env.CookieDomain, env.CookiePath, env.EncryptSecret,
+	)
+	userSessionController := controller.NewSessionAuth(userSessionSvc, sessionCookie)
+	signOutController := controller.NewSignOutHandler(
+		services.NewSignOut(userAccountRepo, userSessionRepo, tokenRepo, personalTokenRepo, eventRepo), sessionCookie,
+	)
+	passwordResetController := controller.NewPasswordResetHandler(
+		services.NewPasswordReset(
+			userAccountRepo, repos.resetToken, userSessionRepo, tokenRepo, personalTokenRepo, eventRepo, mailer,
+			env.ResetURL, env.ResetExpiration,
+		),
+	)
+
+	policyEngine, err := policy.NewEngine(env.PolicyPath)
+	if err != nil {
+		return nil, err
+	}
+	go policyEngine.Watch(env.PolicyInterval, nil)
+	policyController := controller.NewPolicyHandler(policyEngine)
+
+	organizationRegistry := services.NewOrganizationRegistry(repos.organization)
+	tenantController := controller.NewTenantHandler(organizationRegistry)
+	organizationController := controller.NewOrganizationHandler(organizationRegistry)
 
 	router := gin.Default()
 	if err := router.SetTrustedProxies(nil); err != nil {
 		return nil, err
 	}
+	// 全てのルートでテナントを特定する。/t/{slug} のプレフィックスはルーティングの前にStripTenantPrefixで取り除く
+	router.Use(tenantController.Resolve)
 	v1 := router.Group("v1")
+	v1.GET(".well-known/jwks.json", tokenAuthController.JWKS)
 	usersRouter := v1.Group("users") // デバック用APIのため各認証グループ外に設定
 	{
-		usersRouter.GET("", userAccountController.List)
+		usersRouter.GET(
+			"",
+			tokenAuthController.VerifyIDToken,
+			policyController.Require(models.ActionList, models.ResourceUser),
+			userAccountController.List,
+		)
 		usersRouter.POST("new", userAccountController.Create)
+		usersRouter.POST("verify", emailVerificationController.Verify)
+		usersRouter.POST("verify/resend", emailVerificationController.Resend)
+		usersRouter.POST("password/forgot", passwordResetController.Forgot)
+		usersRouter.POST("password/reset", passwordResetController.Reset)
 	}
 
 	{
 		sessionRouter := v1.Group("session")
 		sessionRouter.POST("login", userSessionController.Login)
-		sessionRouter.Use(userSessionController.CheckAuthenticatedOwner).DELETE("logout/:id", userSessionController.Logout)
+		sessionRouter.DELETE(
+			"logout/:id",
+			userSessionController.CheckAuthenticated,
+			policyController.Require(models.ActionDelete, models.ResourceSession),
+			userSessionController.Logout,
+		)
 		{
-			r := sessionRouter.Group("users").Use(userSessionController.CheckAuthenticatedOwner)
+			r := sessionRouter.Group("users").Use(userSessionController.CheckAuthenticated)
 			{
-				r.GET(":id", userAccountController.Get)
-				r.PUT(":id", userAccountController.Update)
-				r.DELETE(":id", userAccountController.Delete)
+				r.GET(":id", policyController.Require(models.ActionRead, models.ResourceUser), userAccountController.Get)
+				r.PUT(":id", policyController.Require(models.ActionUpdate, models.ResourceUser), userAccountController.Update)
+				r.DELETE(":id", policyController.Require(models.ActionDelete, models.ResourceUser), userAccountController.Delete)
+				r.PUT(
+					":id/password",
+					policyController.Require(models.ActionUpdate, models.ResourceUser),
+					userAccountController.ChangePassword,
+				)
+				r.GET(
+					":id/sessions",
+					policyController.Require(models.ActionList, models.ResourceSession),
+					userSessionController.ListSessions,
+				)
+				r.DELETE(
+					":id/sessions",
+					policyController.Require(models.ActionDelete, models.ResourceSession),
+					userSessionController.SignOutOthers,
+				)
+				r.DELETE(
+					":id/sessions/:sid",
+					policyController.Require(models.ActionDelete, models.ResourceSession),
+					userSessionController.RevokeSession,
+				)
+				r.POST(
+					":id/signout",
+					policyController.Require(models.ActionDelete, models.ResourceSession),
+					signOutController.SignOutEverywhere,
+				)
 			}
 		}
 
 		authRouter := v1.Group("auth")
 		authRouter.POST("claim", tokenAuthController.Claim)
 		authRouter.POST("refresh", tokenAuthController.Refresh)
+		authRouter.GET("authorize", tokenAuthController.AuthorizePage)
+		authRouter.POST("authorize", tokenAuthController.Authorize)
+		authRouter.POST("token", tokenAuthController.Token)
+		authRouter.POST("revoke", tokenAuthController.Revoke)
+		authRouter.POST("introspect", tokenAuthController.Introspect)
+		authRouter.GET("userinfo", tokenAuthController.UserInfo)
 		{
 			r := authRouter.Group("users").Use(tokenAuthController.VerifyIDToken)
 			{
-				r.GET(":id", userAccountController.Get)
-				r.PUT(":id", userAccountController.Update)
-				r.DELETE(":id", userAccountController.Delete)
+				r.GET(":id", policyController.Require(models.ActionRead, models.ResourceUser), userAccountController.Get)
+				r.PUT(":id", policyController.Require(models.ActionUpdate, models.ResourceUser), userAccountController.Update)
+				r.DELETE(":id", policyController.Require(models.ActionDelete, models.ResourceUser), userAccountController.Delete)
+				r.PUT(
+					":id/password",
+					policyController.Require(models.ActionUpdate, models.ResourceUser),
+					userAccountController.ChangePassword,
+				)
+				r.POST(
+					":id/signout",
+					policyController.Require(models.ActionDelete, models.ResourceSession),
+					signOutController.SignOutEverywhere,
+				)
 			}
 		}
+		{
+			r := authRouter.Group("tokens").Use(tokenAuthController.VerifyIDToken)
+			{
+				r.GET("", policyController.RequireSelf(models.ActionList, models.ResourcePAT), personalTokenController.List)
+				r.POST("", policyController.RequireSelf(models.ActionCreate, models.ResourcePAT), personalTokenController.Create)
+				r.DELETE(
+					":token_id",
+					policyController.RequireSelf(models.ActionDelete, models.ResourcePAT),
+					personalTokenController.Revoke,
+				)
+			}
+		}
+	}
+
+	// 運用者向けAPIは管理者ロールを持つ主体のみ利用できる
+	adminController := controller.NewAdminHandler(
+		env.AdminToken, tokenAuthSvc, services.NewUserAdministration(userAccountRepo, userSessionRepo, tokenRepo, personalTokenRepo),
+	)
+	adminRouter := v1.Group("admin").Use(adminController.Authenticate, controller.RequireRole(models.RoleAdmin))
+	{
+		adminRouter.POST(
+			"keys/rotate",
+			policyController.Require(models.ActionUpdate, models.ResourceSigningKey),
+			tokenAuthController.RotateKey,
+		)
+		adminRouter.POST(
+			"clients", policyController.Require(models.ActionCreate, models.ResourceClient), clientController.Create,
+		)
+		adminRouter.POST(
+			"users", policyController.Require(models.ActionCreate, models.ResourceUser), adminController.CreateUser,
+		)
+		adminRouter.POST(
+			"users/:id/disable",
+			policyController.Require(models.ActionUpdate, models.ResourceUser),
+			adminController.DisableUser,
+		)
+		adminRouter.POST(
+			"users/:id/enable",
+			policyController.Require(models.ActionUpdate, models.ResourceUser),
+			adminController.EnableUser,
+		)
+		adminRouter.GET(
+			"users/:id/sessions",
+			policyController.Require(models.ActionList, models.ResourceSession),
+			adminController.ListSessions,
+		)
+		adminRouter.DELETE(
+			"users/:id/sessions",
+			policyController.Require(models.ActionDelete, models.ResourceSession),
+			adminController.ForceLogout,
+		)
+		adminRouter.GET(
+			"users/:id/tokens",
+			policyController.Require(models.ActionList, models.ResourceRefreshToken),
+			adminController.ListRefreshTokens,
+		)
+		adminRouter.POST(
+			"organizations",
+			controller.RequireDefaultOrganization,
+			policyController.Require(models.ActionCreate, models.ResourceOrganization),
+			organizationController.Create,
+		)
+		adminRouter.GET("metrics", gin.WrapH(expvar.Handler()))
 	}
 
 	docs.SwaggerInfo.BasePath = "/v1"
 	v1.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
-	return router, nil
+
+	// 他のルートを全て登録した後に、登録済みのルートからディスカバリを組み立てる
+	discoveryController := controller.NewDiscoveryHandler(newOpenIDConfiguration(env, router.Routes()))
+	router.GET(DiscoveryPath, discoveryController.OpenIDConfiguration)
+	return controller.StripTenantPrefix(router), nil
 }
@@ -0,0 +1,758 @@
+// Package storetest 全ての保存先のリポジトリが満たすべき振る舞いを検証する
+// 各保存先のテストから Run を呼び出して利用する
+package storetest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-test/models"
+	"auth-test/services"
+)
+
+// Accessors 検証対象のリポジトリ
+// 既定の組織と定義済みのロールは登録済みである必要がある
+type Accessors struct {
+	UserAccount   models.UserAccountAccessor
+	UserSession   models.UserSessionAccessor
+	Token         models.TokenAccessor
+	Revoked       models.RevokedTokenAccessor
+	Code          models.AuthorizationCodeAccessor
+	Client        models.ClientAccessor
+	Organization  models.OrganizationAccessor
+	Role          models.RoleAccessor
+	PersonalToken models.PersonalAccessTokenAccessor
+	Event         models.SecurityEventRecorder
+	ResetToken    models.PasswordResetTokenAccessor
+	SigningKey    models.SigningKeyAccessor
+	Locker        models.Locker
+}
+
+// Run 保存先を共有しても結果が変わらないよう、テストごとに新しいIDとemailを使用する
+func Run(t *testing.T, newAccessors func(t *testing.T) Accessors) {
+	tests := map[string]func(*testing.T, Accessors){
+		"UserAccount":         testUserAccount,
+		"UserSession":         testUserSession,
+		"Token":               testToken,
+		"RevokedToken":        testRevokedToken,
+		"AuthorizationCode":   testAuthorizationCode,
+		"Client":              testClient,
+		"Organization":        testOrganization,
+		"Role":                testRole,
+		"PersonalAccessToken": testPersonalAccessToken,
+		"SecurityEvent":       testSecurityEvent,
+		"ExpiredSession":      testExpiredSession,
+		"ExpiredToken":        testExpiredToken,
+		"PasswordResetToken":  testPasswordResetToken,
+		"SigningKey":          testSigningKey,
+		"Locker":              testLocker,
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) { test(t, newAccessors(t)) })
+	}
+}
+
+// now DBは秒単位で保存するため、比較する日時は秒に切り捨てる
+func now() time.Time { return time.Now().UTC().Truncate(time.Second) }
+
+func newID() string { return uuid.New().String() }
+
+func newEmail() string { return newID() + "@example.com" }
+
+func insertAccount(t *testing.T, a Accessors) models.UserAccount {
+	t.Helper()
+	account, err := a.UserAccount.Insert(models.DefaultOrganizationID, newID(), newEmail(), "name", "password")
+	if err != nil {
+		t.Fatalf("ユーザの登録に失敗: %v", err)
+	}
+	return *account
+}
+
+func assertErr(t *testing.T, err, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Fatalf("エラーが一致しません: got %v, want %v", err, want)
+	}
+}
+
+func assertNoErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("予期しないエラー: %v", err)
+	}
+}
+
+func testUserAccount(t *testing.T, a Accessors) {
+	orgID := models.DefaultOrganizationID
+	id, email := newID(), newEmail()
+
+	account, err := a.UserAccount.Insert(orgID, id, email, "name", "password")
+	assertNoErr(t, err)
+	if account.ID() != id || account.Email() != email || account.OrganizationID() != orgID {
+		t.Fatalf("登録したユーザが一致しません: %+v", account)
+	}
+	if err = models.NewEncryptedPassword(account.Password()).MatchWith("password"); err != nil {
+		t.Fatalf("パスワードがハッシュ化されていません: %v", err)
+	}
+
+	_, err = a.UserAccount.Insert(orgID, newID(), email, "other", "password")
+	assertErr(t, err, services.DuplicateUserEmail)
+
+	// emailは組織ごとに一意
+	otherOrg := newID()
+	_, err = a.UserAccount.Insert(otherOrg, newID(), email, "other", "password")
+	assertNoErr(t, err)
+
+	found, err := a.UserAccount.FindByEmail(orgID, email)
+	assertNoErr(t, err)
+	if found.ID() != id {
+		t.Fatalf("emailで取得したユーザが一致しません: %s", found.ID())
+	}
+	_, err = a.UserAccount.FindByEmail(orgID, newEmail())
+	assertErr(t, err, services.NoUserEmail)
+	_, err = a.UserAccount.Find(newID())
+	assertErr(t, err, services.NoUserRecord)
+
+	accounts, err := a.UserAccount.List(otherOrg)
+	assertNoErr(t, err)
+	if len(accounts) != 1 || accounts[0].Email() != email {
+		t.Fatalf("組織のユーザ一覧が一致しません: %d件", len(accounts))
+	}
+
+	// 値が変わらない更新も成功する
+	assertNoErr(t, a.UserAccount.Enable(id))
+	assertNoErr(t, a.UserAccount.RequirePasswordReset(id))
+	assertNoErr(t, a.UserAccount.RequirePasswordReset(id))
+	disabledAt := now()
+	assertNoErr(t, a.UserAccount.Disable(id, disabledAt))
+	assertNoErr(t, a.UserAccount.Disable(id, disabledAt))
+	found, err = a.UserAccount.Find(id)
+	assertNoErr(t, err)
+	if !found.ResetRequired() || !found.IsDisabled() {
+		t.Fatalf("再設定の要求と無効化が反映されていません")
+	}
+	assertNoErr(t, a.UserAccount.Enable(id))
+	assertNoErr(t, a.UserAccount.Enable(id))
+	assertErr(t, a.UserAccount.Disable(newID(), now()), services.NoUserRecord)
+	assertErr(t, a.UserAccount.Enable(newID()), services.NoUserRecord)
+
+	assertNoErr(t, a.UserAccount.IncrementTokenGeneration(id))
+	assertNoErr(t, a.UserAccount.IncrementTokenGeneration(id))
+	found, err = a.UserAccount.Find(id)
+	assertNoErr(t, err)
+	if found.TokenGeneration() != 2 {
+		t.Fatalf("トークンの世代が一致しません: %d", found.TokenGeneration())
+	}
+	assertErr(t, a.UserAccount.IncrementTokenGeneration(newID()), services.NoUserRecord)
+
+	// 確認用のリンクを2度開いた場合も成功する
+	assertNoErr(t, a.UserAccount.VerifyEmail(id))
+	assertNoErr(t, a.UserAccount.VerifyEmail(id))
+	found, err = a.UserAccount.Find(id)
+	assertNoErr(t, err)
+	if !found.EmailVerified() {
+		t.Fatalf("メールアドレスの確認が反映されていません")
+	}
+	assertErr(t, a.UserAccount.VerifyEmail(newID()), services.NoUserRecord)
+
+	// メールアドレスを変更しない更新では確認済みのまま。更新日時はUserInfoのupdated_atになるため進める
+	time.Sleep(10 * time.Millisecond)
+	updated, err := a.UserAccount.Update(models.NewUserAccount(orgID, id, email, "name", "password"))
+	assertNoErr(t, err)
+	if !updated.EmailVerified() {
+		t.Fatalf("メールアドレスが確認済みではありません")
+	}
+	if !updated.UpdatedAt().After(found.UpdatedAt()) {
+		t.Fatalf("更新日時が進んでいません: %s -> %s", found.UpdatedAt(), updated.UpdatedAt())
+	}
+
+	// 更新してもパスワードと再設定の要求は変わらず、メールアドレスを変更すると未確認に戻る
+	newEmail := newEmail()
+	updated, err = a.UserAccount.Update(models.NewUserAccount(orgID, id, newEmail, "renamed", "new-password"))
+	assertNoErr(t, err)
+	if updated.Email() != newEmail || updated.Name() != "renamed" || !updated.ResetRequired() || updated.IsDisabled() ||
+		updated.TokenGeneration() != 2 || updated.EmailVerified() {
+		t.Fatalf("更新したユーザが一致しません: %+v", updated)
+	}
+	if err = models.NewEncryptedPassword(updated.Password()).MatchWith("password"); err != nil {
+		t.Fatalf("パスワードが変更されています: %v", err)
+	}
+
+	// パスワードを変更すると再設定の要求は解除される
+	assertNoErr(t, a.UserAccount.UpdatePassword(id, "new-password"))
+	found, err = a.UserAccount.Find(id)
+	assertNoErr(t, err)
+	if found.ResetRequired() || found.Email() != newEmail {
+		t.Fatalf("パスワードの変更が反映されていません: %+v", found)
+	}
+	if err = models.NewEncryptedPassword(found.Password()).MatchWith("new-password"); err != nil {
+		t.Fatalf("変更したパスワードが一致しません: %v", err)
+	}
+	assertErr(t, a.UserAccount.UpdatePassword(newID(), "password"), services.NoUserRecord)
+
+	other := insertAccount(t, a)
+	_, err = a.UserAccount.Update(models.NewUserAccount(orgID, other.ID(), newEmail, "name", "password"))
+	assertErr(t, err, services.DuplicateUserEmail)
+	_, err = a.UserAccount.Update(models.NewUserAccount(orgID, newID(), newEmail, "name", "password"))
+	assertErr(t, err, services.NoUserRecord)
+
+	assertNoErr(t, a.UserAccount.Delete(id))
+	assertErr(t, a.UserAccount.Delete(id), services.NoUserRecord)
+	assertErr(t, a.UserAccount.Delete("invalid"), services.InvalidUUIDFormat)
+}
+
+func testUserSession(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	owner := account.ID()
+	current := now()
+
+	first, second := newID(), newID()
+	for _, token := range []string{first, second} {
+		registered, err := a.UserSession.Register(
+			models.NewSession(owner, token, "192.0.2.1", "agent", current.Add(time.Hour), current.Add(2*time.Hour)),
+		)
+		assertNoErr(t, err)
+		if registered != token {
+			t.Fatalf("登録したセッションが一致しません: %s", registered)
+		}
+	}
+
+	sess, err := a.UserSession.FindOwner(first, current)
+	assertNoErr(t, err)
+	if sess.Owner() != owner || sess.IPAddress() != "192.0.2.1" || sess.UserAgent() != "agent" {
+		t.Fatalf("取得したセッションが一致しません: %+v", sess)
+	}
+	if !sess.ExpiredAt().Equal(current.Add(time.Hour)) || !sess.AbsoluteExpiredAt().Equal(current.Add(2*time.Hour)) {
+		t.Fatalf("セッションの期限が一致しません: %s, %s", sess.ExpiredAt(), sess.AbsoluteExpiredAt())
+	}
+	_, err = a.UserSession.FindOwner(first, current.Add(time.Hour))
+	assertErr(t, err, services.NoSessionRecord)
+	_, err = a.UserSession.FindOwner(newID(), current)
+	assertErr(t, err, services.NoSessionRecord)
+
+	// 期限を短くする延長は反映されない
+	assertNoErr(t, a.UserSession.Extend(first, current, current.Add(90*time.Minute)))
+	assertNoErr(t, a.UserSession.Extend(first, current, current.Add(30*time.Minute)))
+	sess, err = a.UserSession.FindOwner(first, current)
+	assertNoErr(t, err)
+	if !sess.ExpiredAt().Equal(current.Add(90 * time.Minute)) {
+		t.Fatalf("延長した期限が一致しません: %s", sess.ExpiredAt())
+	}
+
+	sessions, err := a.UserSession.ListByOwner(owner)
+	assertNoErr(t, err)
+	if len(sessions) != 2 || sessions[0].Token() != first || sessions[1].Token() != second {
+		t.Fatalf("セッションの一覧がログインした順になっていません: %d件", len(sessions))
+	}
+
+	assertErr(t, a.UserSession.Delete(newID(), first), services.NoSessionRecord)
+	assertNoErr(t, a.UserSession.DeleteOthers(owner, first))
+	sessions, err = a.UserSession.ListByOwner(owner)
+	assertNoErr(t, err)
+	if len(sessions) != 1 || sessions[0].Token() != first {
+		t.Fatalf("指定したセッション以外が削除されていません: %d件", len(sessions))
+	}
+
+	assertNoErr(t, a.UserSession.Delete(owner, first))
+	assertErr(t, a.UserSession.Delete(owner, first), services.NoSessionRecord)
+
+	_, err = a.UserSession.Register(
+		models.NewSession(owner, newID(), "", "", current.Add(time.Hour), current.Add(time.Hour)),
+	)
+	assertNoErr(t, err)
+	assertNoErr(t, a.UserSession.DeleteByOwner(owner))
+	sessions, err = a.UserSession.ListByOwner(owner)
+	assertNoErr(t, err)
+	if len(sessions) != 0 {
+		t.Fatalf("ユーザのセッションが削除されていません: %d件", len(sessions))
+	}
+}
+
+func testToken(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	owner := account.ID()
+	current := now()
+
+	family, next := newID(), newID()
+	value, err := a.Token.Insert(
+		models.NewRefreshTokenInput(owner, family, family, "client", "openid", current.Add(time.Hour)),
+	)
+	assertNoErr(t, err)
+	if value != family {
+		t.Fatalf("登録したトークンが一致しません: %s", value)
+	}
+	_, err = a.Token.Insert(models.NewRefreshTokenInput(owner, family, family, "", "", current.Add(time.Hour)))
+	assertErr(t, err, services.DuplicateToken)
+
+	token, err := a.Token.FindOwner(family, current)
+	assertNoErr(t, err)
+	if token.Owner().ID() != owner || token.Owner().Email() != account.Email() ||
+		token.Owner().OrganizationID() != models.DefaultOrganizationID {
+		t.Fatalf("トークンの持ち主が一致しません: %+v", token.Owner())
+	}
+	if token.FamilyID() != family || token.ClientID() != "client" || token.Scope() != "openid" {
+		t.Fatalf("取得したトークンが一致しません: %+v", token)
+	}
+	_, err = a.Token.FindOwner(family, current.Add(time.Hour))
+	assertErr(t, err, services.NoTokenRecord)
+
+	// 失効済みのトークンはFindでのみ取得できる
+	assertNoErr(t, a.Token.Revoke(family, current))
+	assertErr(t, a.Token.Revoke(family, current), services.RevokedToken)
+	_, err = a.Token.FindOwner(family, current)
+	assertErr(t, err, services.NoTokenRecord)
+	token, err = a.Token.Find(family)
+	assertNoErr(t, err)
+	if !token.IsRevoked() {
+		t.Fatalf("トークンが失効していません")
+	}
+	_, err = a.Token.Find(newID())
+	assertErr(t, err, services.NoTokenRecord)
+
+	_, err = a.Token.Insert(models.NewRefreshTokenInput(owner, next, family, "client", "openid", current.Add(time.Hour)))
+	assertNoErr(t, err)
+	tokens, err := a.Token.ListByOwner(owner)
+	assertNoErr(t, err)
+	if len(tokens) != 1 || tokens[0].Value() != next {
+		t.Fatalf("有効なトークンの一覧が一致しません: %d件", len(tokens))
+	}
+
+	// ファミリーIDが空の場合は何も失効させない
+	assertNoErr(t, a.Token.RevokeFamily("", current))
+	tokens, err = a.Token.ListByOwner(owner)
+	assertNoErr(t, err)
+	if len(tokens) != 1 {
+		t.Fatalf("空のファミリーIDで失効しています: %d件", len(tokens))
+	}
+
+	assertNoErr(t, a.Token.RevokeFamily(family, current))
+	tokens, err = a.Token.ListByOwner(owner)
+	assertNoErr(t, err)
+	if len(tokens) != 0 {
+		t.Fatalf("ファミリーが失効していません: %d件", len(tokens))
+	}
+
+	assertNoErr(t, a.Token.Delete(next))
+	assertErr(t, a.Token.Delete(next), services.NoTokenRecord)
+	assertNoErr(t, a.Token.DeleteByOwner(owner))
+	_, err = a.Token.Find(family)
+	assertErr(t, err, services.NoTokenRecord)
+}
+
+// testExpiredSession 他のテストのデータを削除しないよう、過去の時刻を基準にする
+func testExpiredSession(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	owner := account.ID()
+	base := now().AddDate(-1, 0, 0)
+
+	idle, absolute, alive := newID(), newID(), newID()
+	for _, sess := range []models.Session{
+		models.NewSession(owner, idle, "", "", base, base.Add(2*time.Hour)),
+		models.NewSession(owner, absolute, "", "", base.Add(2*time.Hour), base),
+		models.NewSession(owner, alive, "", "", base.Add(2*time.Hour), base.Add(2*time.Hour)),
+	} {
+		_, err := a.UserSession.Register(sess)
+		assertNoErr(t, err)
+	}
+
+	deleted, err := a.UserSession.DeleteExpired(base, 1)
+	assertNoErr(t, err)
+	if deleted != 1 {
+		t.Fatalf("削除件数が上限と一致しません: %d件", deleted)
+	}
+	deleted, err = a.UserSession.DeleteExpired(base, 10)
+	assertNoErr(t, err)
+	if deleted < 1 {
+		t.Fatalf("期限切れのセッションが削除されていません: %d件", deleted)
+	}
+
+	assertErr(t, a.UserSession.Delete(owner, idle), services.NoSessionRecord)
+	assertErr(t, a.UserSession.Delete(owner, absolute), services.NoSessionRecord)
+	assertNoErr(t, a.UserSession.Delete(owner, alive))
+}
+
+func testExpiredToken(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	owner := account.ID()
+	base := now().AddDate(-1, 0, 0)
+
+	expired, revoked := newID(), newID()
+	_, err := a.Token.Insert(models.NewRefreshTokenInput(owner, expired, expired, "", "", base))
+	assertNoErr(t, err)
+	_, err = a.Token.Insert(models.NewRefreshTokenInput(owner, revoked, revoked, "", "", base.Add(time.Hour)))
+	assertNoErr(t, err)
+	assertNoErr(t, a.Token.Revoke(revoked, base))
+
+	deleted, err := a.Token.DeleteExpired(base, 10)
+	assertNoErr(t, err)
+	if deleted < 1 {
+		t.Fatalf("期限切れのトークンが削除されていません: %d件", deleted)
+	}
+
+	// 失効済みでも期限内のトークンは残す
+	_, err = a.Token.Find(expired)
+	assertErr(t, err, services.NoTokenRecord)
+	_, err = a.Token.Find(revoked)
+	assertNoErr(t, err)
+}
+
+func testPasswordResetToken(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	owner := account.ID()
+	current := now()
+
+	hash := models.HashPasswordResetToken(newID())
+	assertNoErr(t, a.ResetToken.Insert(models.NewPasswordResetToken(hash, owner, current.Add(time.Hour))))
+	assertErr(
+		t, a.ResetToken.Insert(models.NewPasswordResetToken(hash, owner, current.Add(time.Hour))), services.DuplicateToken,
+	)
+
+	// 期限切れのトークンは消費できない
+	_, err := a.ResetToken.Consume(hash, current.Add(time.Hour))
+	assertErr(t, err, services.NoResetToken)
+
+	token, err := a.ResetToken.Consume(hash, current)
+	assertNoErr(t, err)
+	if token.Hash() != hash || token.AccountID() != owner || !token.ExpiredAt().Equal(current.Add(time.Hour)) {
+		t.Fatalf("消費したトークンが一致しません: %+v", token)
+	}
+	_, err = a.ResetToken.Consume(hash, current)
+	assertErr(t, err, services.NoResetToken)
+
+	other := models.HashPasswordResetToken(newID())
+	assertNoErr(t, a.ResetToken.Insert(models.NewPasswordResetToken(other, owner, current.Add(time.Hour))))
+	assertNoErr(t, a.ResetToken.DeleteByOwner(owner))
+	_, err = a.ResetToken.Consume(other, current)
+	assertErr(t, err, services.NoResetToken)
+
+	// 他のテストのデータを削除しないよう、過去の時刻を基準にする
+	base := current.AddDate(-1, 0, 0)
+	expired, alive := models.HashPasswordResetToken(newID()), models.HashPasswordResetToken(newID())
+	assertNoErr(t, a.ResetToken.Insert(models.NewPasswordResetToken(expired, owner, base)))
+	assertNoErr(t, a.ResetToken.Insert(models.NewPasswordResetToken(alive, owner, base.Add(time.Hour))))
+	deleted, err := a.ResetToken.DeleteExpired(base, 10)
+	assertNoErr(t, err)
+	if deleted < 1 {
+		t.Fatalf("期限切れのトークンが削除されていません: %d件", deleted)
+	}
+	_, err = a.ResetToken.Consume(expired, base.Add(-time.Minute))
+	assertErr(t, err, services.NoResetToken)
+	_, err = a.ResetToken.Consume(alive, base)
+	assertNoErr(t, err)
+}
+
+func testLocker(t *testing.T, a Accessors) {
+	name := newID()
+
+	release, ok, err := a.Locker.TryLock(name)
+	assertNoErr(t, err)
+	if !ok {
+		t.Fatalf("ロックを取得できません")
+	}
+
+	_, ok, err = a.Locker.TryLock(name)
+	assertNoErr(t, err)
+	if ok {
+		t.Fatalf("取得済みのロックを重複して取得できます")
+	}
+
+	release()
+	release, ok, err = a.Locker.TryLock(name)
+	assertNoErr(t, err)
+	if !ok {
+		t.Fatalf("解放したロックを取得できません")
+	}
+
+	// Lockは待機時間内に解放されなければエラーになり、解放されれば取得できる
+	_, err = a.Locker.Lock(name, 100*time.Millisecond)
+	assertErr(t, err, services.LockTimeout)
+	time.AfterFunc(100*time.Millisecond, release)
+	release, err = a.Locker.Lock(name, 3*time.Second)
+	assertNoErr(t, err)
+	release()
+}
+
+func testRevokedToken(t *testing.T, a Accessors) {
+	id := newID()
+	exists, err := a.Revoked.Exists(id)
+	assertNoErr(t, err)
+	if exists {
+		t.Fatalf("失効していないトークンが失効済みになっています")
+	}
+
+	assertNoErr(t, a.Revoked.Insert(id, now().Add(time.Hour)))
+	assertNoErr(t, a.Revoked.Insert(id, now().Add(time.Hour)))
+	exists, err = a.Revoked.Exists(id)
+	assertNoErr(t, err)
+	if !exists {
+		t.Fatalf("トークンが失効済みになっていません")
+	}
+}
+
+func testAuthorizationCode(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	current := now()
+
+	code := models.NewAuthorizationCode(
+		newID(), account.ID(), "client", "https://example.com/cb", "openid", "challenge", current.Add(time.Minute),
+	)
+	value, err := a.Code.Insert(code)
+	assertNoErr(t, err)
+	if value != code.Code() {
+		t.Fatalf("登録した認可コードが一致しません: %s", value)
+	}
+	_, err = a.Code.Insert(code)
+	assertErr(t, err, services.DuplicateToken)
+
+	consumed, err := a.Code.Consume(code.Code(), current)
+	assertNoErr(t, err)
+	if consumed.AccountID() != account.ID() || consumed.RedirectURI() != code.RedirectURI() ||
+		consumed.CodeChallenge() != code.CodeChallenge() {
+		t.Fatalf("取得した認可コードが一致しません: %+v", consumed)
+	}
+	// 認可コードは1度しか使えない
+	_, err = a.Code.Consume(code.Code(), current)
+	assertErr(t, err, services.NoAuthorizationCode)
+
+	expired := models.NewAuthorizationCode(
+		newID(), account.ID(), "client", "https://example.com/cb", "openid", "challenge", current,
+	)
+	_, err = a.Code.Insert(expired)
+	assertNoErr(t, err)
+	_, err = a.Code.Consume(expired.Code(), current)
+	assertErr(t, err, services.NoAuthorizationCode)
+}
+
+func testClient(t *testing.T, a Accessors) {
+	id := newID()
+	client, err := a.Client.Insert(models.NewClient(
+		id, "confidential", "secret", []string{"https://example.com/cb"},
+		[]string{"authorization_code", "refresh_token"}, []string{"openid", "email"},
+	))
+	assertNoErr(t, err)
+	// シークレットはハッシュ化して保存する
+	if err = models.NewEncryptedPassword(client.Secret()).MatchWith("secret"); err != nil {
+		t.Fatalf("シークレットがハッシュ化されていません: %v", err)
+	}
+	if !client.AllowsRedirectURI("https://example.com/cb") || !client.AllowsGrant("refresh_token") ||
+		!client.AllowsScope("email") {
+		t.Fatalf("登録したクライアントが一致しません: %+v", client)
+	}
+
+	_, err = a.Client.Insert(models.NewClient(id, "duplicate", "secret", nil, nil, nil))
+	assertErr(t, err, services.DuplicateClient)
+
+	public, err := a.Client.Insert(models.NewClient(
+		newID(), "public", "", []string{"https://example.com/cb"}, []string{"authorization_code"}, nil,
+	))
+	assertNoErr(t, err)
+	if !public.IsPublic() {
+		t.Fatalf("公開クライアントがシークレットを持っています")
+	}
+
+	found, err := a.Client.Find(id)
+	assertNoErr(t, err)
+	if found.Name() != "confidential" {
+		t.Fatalf("取得したクライアントが一致しません: %s", found.Name())
+	}
+	_, err = a.Client.Find(newID())
+	assertErr(t, err, services.NoClientRecord)
+}
+
+func testOrganization(t *testing.T, a Accessors) {
+	defaultOrg, err := a.Organization.Find(models.DefaultOrganizationID)
+	assertNoErr(t, err)
+	if defaultOrg.ID() != models.DefaultOrganizationID {
+		t.Fatalf("既定の組織が一致しません: %s", defaultOrg.ID())
+	}
+
+	id := newID()
+	slug, host := id[:8], id[:8]+".example.com"
+	organization, err := a.Organization.Insert(models.NewOrganization(id, slug, "name", host))
+	assertNoErr(t, err)
+	if organization.ID() != id || organization.Host() != host {
+		t.Fatalf("登録した組織が一致しません: %+v", organization)
+	}
+
+	found, err := a.Organization.FindBySlug(slug)
+	assertNoErr(t, err)
+	if found.ID() != id {
+		t.Fatalf("slugで取得した組織が一致しません: %s", found.ID())
+	}
+	found, err = a.Organization.FindByHost(host)
+	assertNoErr(t, err)
+	if found.ID() != id {
+		t.Fatalf("hostで取得した組織が一致しません: %s", found.ID())
+	}
+
+	_, err = a.Organization.Insert(models.NewOrganization(newID(), slug, "duplicate", ""))
+	assertErr(t, err, services.DuplicateTenant)
+	_, err = a.Organization.Insert(models.NewOrganization(newID(), newID()[:8], "duplicate", host))
+	assertErr(t, err, services.DuplicateTenant)
+
+	// hostを持たない組織は複数登録でき、Hostヘッダからは特定しない
+	for i := 0; i < 2; i++ {
+		_, err = a.Organization.Insert(models.NewOrganization(newID(), newID()[:8], "no host", ""))
+		assertNoErr(t, err)
+	}
+	_, err = a.Organization.FindByHost("")
+	assertErr(t, err, services.NoTenantRecord)
+
+	_, err = a.Organization.Find(newID())
+	assertErr(t, err, services.NoTenantRecord)
+	_, err = a.Organization.FindBySlug(newID())
+	assertErr(t, err, services.NoTenantRecord)
+}
+
+func testRole(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+
+	roles, err := a.Role.FindByAccount(account.ID())
+	assertNoErr(t, err)
+	if len(roles) != 0 {
+		t.Fatalf("ロールが割り当てられています: %v", roles)
+	}
+
+	// 割り当て済みのロールを割り当ててもエラーにならない
+	assertNoErr(t, a.Role.Assign(account.ID(), models.RoleSupport))
+	assertNoErr(t, a.Role.Assign(account.ID(), models.RoleAdmin))
+	assertNoErr(t, a.Role.Assign(account.ID(), models.RoleAdmin))
+	roles, err = a.Role.FindByAccount(account.ID())
+	assertNoErr(t, err)
+	if len(roles) != 2 || roles[0] != models.RoleAdmin || roles[1] != models.RoleSupport {
+		t.Fatalf("ロールが名前順に取得できません: %v", roles)
+	}
+
+	assertNoErr(t, a.Role.Unassign(account.ID(), models.RoleAdmin))
+	roles, err = a.Role.FindByAccount(account.ID())
+	assertNoErr(t, err)
+	if len(roles) != 1 || roles[0] != models.RoleSupport {
+		t.Fatalf("ロールの割り当てが解除されていません: %v", roles)
+	}
+
+	assertErr(t, a.Role.Assign(account.ID(), "undefined"), services.NoRoleRecord)
+	assertErr(t, a.Role.Unassign(account.ID(), "undefined"), services.NoRoleRecord)
+}
+
+func testPersonalAccessToken(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	owner := account.ID()
+	current := now()
+
+	newToken := func(expiredAt time.Time) models.PersonalAccessToken {
+		return models.NewPersonalAccessToken(
+			newID(), models.DefaultOrganizationID, owner, "ci", "user:read", current, expiredAt, time.Time{},
+		)
+	}
+
+	first, hash := newToken(current.Add(time.Hour)), models.HashPersonalAccessToken(newID())
+	inserted, err := a.PersonalToken.Insert(first, hash)
+	assertNoErr(t, err)
+	if inserted.ID() != first.ID() || inserted.OwnerID() != owner || inserted.Scope() != "user:read" {
+		t.Fatalf("登録したトークンが一致しません: %+v", inserted)
+	}
+	_, err = a.PersonalToken.Insert(newToken(current.Add(time.Hour)), hash)
+	assertErr(t, err, services.DuplicateToken)
+
+	found, err := a.PersonalToken.FindByHash(hash)
+	assertNoErr(t, err)
+	if found.ID() != first.ID() {
+		t.Fatalf("ハッシュ値で取得したトークンが一致しません: %s", found.ID())
+	}
+	_, err = a.PersonalToken.FindByHash(models.HashPersonalAccessToken(newID()))
+	assertErr(t, err, services.NoTokenRecord)
+
+	second := newToken(current.Add(2 * time.Hour))
+	_, err = a.PersonalToken.Insert(second, models.HashPersonalAccessToken(newID()))
+	assertNoErr(t, err)
+	_, err = a.PersonalToken.Insert(newToken(current), models.HashPersonalAccessToken(newID()))
+	assertNoErr(t, err)
+
+	tokens, err := a.PersonalToken.ListByOwner(owner, current)
+	assertNoErr(t, err)
+	if len(tokens) != 2 || tokens[0].ID() != first.ID() || tokens[1].ID() != second.ID() {
+		t.Fatalf("有効なトークンの一覧が一致しません: %d件", len(tokens))
+	}
+
+	// 持ち主以外は失効できない
+	assertErr(t, a.PersonalToken.Revoke(newID(), first.ID(), current), services.NoTokenRecord)
+	assertNoErr(t, a.PersonalToken.Revoke(owner, first.ID(), current))
+	assertErr(t, a.PersonalToken.Revoke(owner, first.ID(), current), services.NoTokenRecord)
+	found, err = a.PersonalToken.FindByHash(hash)
+	assertNoErr(t, err)
+	if !found.IsRevoked() {
+		t.Fatalf("トークンが失効していません")
+	}
+
+	tokens, err = a.PersonalToken.ListByOwner(owner, current)
+	assertNoErr(t, err)
+	if len(tokens) != 1 || tokens[0].ID() != second.ID() {
+		t.Fatalf("失効したトークンが一覧に含まれています: %d件", len(tokens))
+	}
+
+	// 他のユーザのトークンは失効させない
+	other := insertAccount(t, a)
+	otherToken := models.NewPersonalAccessToken(
+		newID(), models.DefaultOrganizationID, other.ID(), "ci", "user:read", current, current.Add(time.Hour), time.Time{},
+	)
+	_, err = a.PersonalToken.Insert(otherToken, models.HashPersonalAccessToken(newID()))
+	assertNoErr(t, err)
+
+	assertNoErr(t, a.PersonalToken.RevokeByOwner(owner, current))
+	assertNoErr(t, a.PersonalToken.RevokeByOwner(owner, current))
+	tokens, err = a.PersonalToken.ListByOwner(owner, current)
+	assertNoErr(t, err)
+	if len(tokens) != 0 {
+		t.Fatalf("全てのトークンが失効していません: %d件", len(tokens))
+	}
+	tokens, err = a.PersonalToken.ListByOwner(other.ID(), current)
+	assertNoErr(t, err)
+	if len(tokens) != 1 {
+		t.Fatalf("他のユーザのトークンが失効しています: %d件", len(tokens))
+	}
+}
+
+func testSecurityEvent(t *testing.T, a Accessors) {
+	account := insertAccount(t, a)
+	assertNoErr(t, a.Event.Record(
+		models.NewSecurityEvent(models.EventRefreshTokenReuse, account.ID(), "detail", now()),
+	))
+}
+
+func testSigningKey(t *testing.T, a Accessors) {
+	current := now()
+	first := models.NewSigningKey(newID(), "ES256", "first", current, time.Time{})
+	second := models.NewSigningKey(newID(), "ES256", "second", current, time.Time{})
+	retiredAt := current.Add(time.Hour)
+
+	assertNoErr(t, a.SigningKey.Rotate(first, current))
+	assertNoErr(t, a.SigningKey.Rotate(second, retiredAt))
+
+	// 署名に使う鍵は最後に登録した1つのみで、一覧の最後に並ぶ
+	keys, err := a.SigningKey.List()
+	assertNoErr(t, err)
+	last := keys[len(keys)-1]
+	if last.ID() != second.ID() || last.Material() != "second" || !last.RetiredAt().IsZero() {
+		t.Fatalf("署名に使う鍵が一致しません: %+v", last)
+	}
+
+	var found bool
+	for _, key := range keys[:len(keys)-1] {
+		if key.RetiredAt().IsZero() {
+			t.Fatalf("署名に使う鍵が複数あります: %s", key.ID())
+		}
+		if key.ID() == first.ID() {
+			found = true
+			if !key.RetiredAt().Equal(retiredAt) || key.Algorithm() != "ES256" {
+				t.Fatalf("退役させた鍵が一致しません: %+v", key)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("退役させた鍵が一覧に含まれていません")
+	}
+}
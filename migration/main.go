@@ -10,6 +10,7 @@ import (
 
 	"auth-test/infra/configuration"
 	"auth-test/infra/db"
+	"auth-test/models"
 )
 
 func main() {
@@ -28,18 +29,114 @@ func main() {
 		log.Fatalf("DBへの接続に失敗。: %s \n", err.Error())
 	}
 
+	backfillAbsoluteExpiredAt := mysqlDB.Migrator().HasTable(&db.UserSessions{}) &&
+		!mysqlDB.Migrator().HasColumn(&db.UserSessions{}, "AbsoluteExpiredAt")
 	err = mysqlDB.AutoMigrate(&db.UserSessions{})
 	if err != nil {
 		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
 	}
 
+	// 延長を導入する前のセッションはexpired_atがログインからSessionExpiration後の期限のため、延長の上限とする
+	if backfillAbsoluteExpiredAt {
+		err = mysqlDB.Exec("UPDATE user_sessions SET absolute_expired_at = expired_at").Error
+		if err != nil {
+			log.Fatalf("セッションの有効期限の設定に失敗。: %s \n", err.Error())
+		}
+	}
+
+	err = mysqlDB.AutoMigrate(&db.Organizations{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	// テナントを指定しないリクエストと既存のユーザは既定の組織に属する
+	err = mysqlDB.
+		Where(db.Organizations{ID: models.DefaultOrganizationID}).
+		FirstOrCreate(&db.Organizations{Slug: "default", Name: "default"}).Error
+	if err != nil {
+		log.Fatalf("既定の組織の作成に失敗。: %s \n", err.Error())
+	}
+
 	err = mysqlDB.AutoMigrate(&db.UserAccounts{})
 	if err != nil {
 		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
 	}
 
+	// emailを組織ごとに一意とするため、全体で一意としていたインデックスを削除する
+	if mysqlDB.Migrator().HasIndex(&db.UserAccounts{}, "email") {
+		if err = mysqlDB.Migrator().DropIndex(&db.UserAccounts{}, "email"); err != nil {
+			log.Fatalf("インデックスの削除に失敗。: %s \n", err.Error())
+		}
+	}
+
 	err = mysqlDB.AutoMigrate(&db.Tokens{})
 	if err != nil {
 		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
 	}
+
+	// ファミリーを導入する前のトークンは空のファミリーIDになるため、それぞれ別のファミリーとする
+	err = mysqlDB.Exec("UPDATE tokens SET family_id = UUID() WHERE family_id = ''").Error
+	if err != nil {
+		log.Fatalf("ファミリーIDの設定に失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.SigningKeys{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.RevokedTokens{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.SecurityEvents{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.Clients{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.AuthorizationCodes{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.PersonalAccessTokens{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.PasswordResetTokens{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	err = mysqlDB.AutoMigrate(&db.Roles{}, &db.UserRoles{})
+	if err != nil {
+		log.Fatalf("テーブルのマイグレーションに失敗。: %s \n", err.Error())
+	}
+
+	for _, name := range models.DefinedRoles {
+		err = mysqlDB.Where(db.Roles{Name: name}).FirstOrCreate(&db.Roles{}).Error
+		if err != nil {
+			log.Fatalf("ロールの作成に失敗。: %s \n", err.Error())
+		}
+	}
+
+	// 最初の管理者は環境変数で指定した登録済みのユーザとする
+	if env.AdminEmail != "" {
+		account, err := db.NewUserAccountRepository(*mysqlDB).FindByEmail(models.DefaultOrganizationID, env.AdminEmail)
+		if err != nil {
+			log.Fatalf("管理者ユーザの取得に失敗。: %s \n", err.Error())
+		}
+
+		err = db.NewRoleRepository(*mysqlDB).Assign(account.ID(), models.RoleAdmin)
+		if err != nil {
+			log.Fatalf("管理者ロールの割り当てに失敗。: %s \n", err.Error())
+		}
+	}
 }
@@ -1,46 +1,160 @@
 package models
 
 import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 )
 
 type Authorizer interface {
 	Sign(IDTokenInput) (string, error)
-	Verify(string) error
+	Verify(string) (*Claims, error)
+	PublicKeys() []PublicKey
+	Rotate(time.Time) (string, error)
 }
 
-func NewAccessTokenInput(accountID, email string, now, expiration time.Time) IDTokenInput {
+func NewClaims(
+	orgID, subject, email, clientID, scope, tokenID string, emailVerified bool, roles []string, generation int,
+	issuedAt, expiredAt time.Time,
+) Claims {
+	return Claims{
+		orgID:         orgID,
+		subject:       subject,
+		email:         email,
+		emailVerified: emailVerified,
+		clientID:      clientID,
+		scope:         scope,
+		tokenID:       tokenID,
+		roles:         roles,
+		generation:    generation,
+		issuedAt:      issuedAt,
+		expiredAt:     expiredAt,
+	}
+}
+
+// Claims 署名を検証したIDトークンの内容
+type Claims struct {
+	orgID         string
+	subject       string
+	email         string
+	emailVerified bool
+	clientID      string
+	scope         string
+	tokenID       string
+	roles         []string
+	generation    int
+	issuedAt      time.Time
+	expiredAt     time.Time
+}
+
+func (c Claims) OrganizationID() string { return c.orgID }
+
+func (c Claims) Subject() string      { return c.subject }
+func (c Claims) Email() string        { return c.email }
+func (c Claims) ClientID() string     { return c.clientID }
+func (c Claims) Scope() string        { return c.scope }
+func (c Claims) TokenID() string      { return c.tokenID }
+func (c Claims) Roles() []string      { return c.roles }
+func (c Claims) IssuedAt() time.Time  { return c.issuedAt }
+func (c Claims) ExpiredAt() time.Time { return c.expiredAt }
+func (c Claims) Generation() int      { return c.generation }
+func (c Claims) EmailVerified() bool  { return c.emailVerified }
+
+// IsClient client_credentialsグラントで発行したクライアント自身を主体とするトークン
+func (c Claims) IsClient() bool { return c.clientID != "" && c.subject == c.clientID }
+
+func NewIntrospection(tokenType string, claims Claims) Introspection {
+	return Introspection{tokenType: tokenType, claims: claims}
+}
+
+// Introspection 有効なトークンの種類と内容
+type Introspection struct {
+	tokenType string
+	claims    Claims
+}
+
+func (i Introspection) TokenType() string { return i.tokenType }
+func (i Introspection) Claims() Claims    { return i.claims }
+
+func NewPublicKey(id, algorithm string, key crypto.PublicKey) PublicKey {
+	return PublicKey{id: id, algorithm: algorithm, key: key}
+}
+
+// PublicKey JWKSとして公開する検証用の公開鍵
+type PublicKey struct {
+	id        string
+	algorithm string
+	key       crypto.PublicKey
+}
+
+func (k PublicKey) ID() string            { return k.id }
+func (k PublicKey) Algorithm() string     { return k.algorithm }
+func (k PublicKey) Key() crypto.PublicKey { return k.key }
+
+func NewAccessTokenInput(
+	orgID, accountID, email, clientID, scope string, emailVerified bool, roles []string, generation int,
+	now, expiration time.Time,
+) IDTokenInput {
 	return IDTokenInput{
-		accountID: accountID,
-		email:     email,
-		now:       now,
-		expiredAt: expiration,
+		orgID:         orgID,
+		accountID:     accountID,
+		email:         email,
+		emailVerified: emailVerified,
+		clientID:      clientID,
+		scope:         scope,
+		roles:         roles,
+		generation:    generation,
+		now:           now,
+		expiredAt:     expiration,
 	}
 }
 
 // IDTokenInput TODO Register Claim NamesとPrivate Claim Namesを別途定義して組み込むべき?
 type IDTokenInput struct {
-	accountID string
-	email     string
-	now       time.Time
-	expiredAt time.Time
+	orgID         string
+	accountID     string
+	email         string
+	emailVerified bool
+	clientID      string
+	scope         string
+	roles         []string
+	generation    int
+	now           time.Time
+	expiredAt     time.Time
 }
 
+func (i IDTokenInput) OrganizationID() string { return i.orgID }
+
 func (i IDTokenInput) AccountID() string    { return i.accountID }
 func (i IDTokenInput) Email() string        { return i.email }
+func (i IDTokenInput) ClientID() string     { return i.clientID }
+func (i IDTokenInput) Scope() string        { return i.scope }
+func (i IDTokenInput) Roles() []string      { return i.roles }
 func (i IDTokenInput) Now() time.Time       { return i.now }
 func (i IDTokenInput) ExpiredAt() time.Time { return i.expiredAt }
+func (i IDTokenInput) Generation() int      { return i.generation }
+func (i IDTokenInput) EmailVerified() bool  { return i.emailVerified }
 
 type TokenAccessor interface {
 	Insert(RefreshTokenInput) (string, error)
 	Delete(string) error
-	FindOwner(string, time.Time) (*TokenOwner, error)
+	FindOwner(string, time.Time) (*RefreshToken, error)
+	Find(string) (*RefreshToken, error)
+	Revoke(string, time.Time) error
+	RevokeFamily(string, time.Time) error
+	ListByOwner(string) ([]RefreshToken, error)
+	DeleteByOwner(string) error
+	DeleteExpired(time.Time, int) (int64, error)
 }
 
-func NewRefreshTokenInput(accountID, value string, expiration time.Time) RefreshTokenInput {
+func NewRefreshTokenInput(accountID, value, familyID, clientID, scope string, expiration time.Time) RefreshTokenInput {
 	return RefreshTokenInput{
 		accountID: accountID,
 		value:     value,
+		familyID:  familyID,
+		clientID:  clientID,
+		scope:     scope,
 		expiredAt: expiration,
 	}
 }
@@ -49,28 +163,86 @@ type RefreshTokenInput struct {
 	accountID string
 	expiredAt time.Time
 	value     string
+	familyID  string
+	clientID  string
+	scope     string
 }
 
 func (i RefreshTokenInput) AccountID() string    { return i.accountID }
 func (i RefreshTokenInput) Value() string        { return i.value }
+func (i RefreshTokenInput) FamilyID() string     { return i.familyID }
+func (i RefreshTokenInput) ClientID() string     { return i.clientID }
+func (i RefreshTokenInput) Scope() string        { return i.scope }
 func (i RefreshTokenInput) ExpiredAt() time.Time { return i.expiredAt }
 
-func NewToken(id, refresh string) Token { return Token{idToken: id, refresh: refresh} }
+func NewRefreshToken(
+	value, familyID, clientID, scope string, owner TokenOwner, issuedAt, expiredAt, revokedAt time.Time,
+) RefreshToken {
+	return RefreshToken{
+		value:     value,
+		familyID:  familyID,
+		clientID:  clientID,
+		scope:     scope,
+		owner:     owner,
+		issuedAt:  issuedAt,
+		expiredAt: expiredAt,
+		revokedAt: revokedAt,
+	}
+}
+
+// RefreshToken 登録済みのリフレッシュトークン
+// 同じログインから更新されたトークンは同じfamilyIDを持ち、更新済みのトークンはrevokedAtが設定される
+type RefreshToken struct {
+	value     string
+	familyID  string
+	clientID  string
+	scope     string
+	owner     TokenOwner
+	issuedAt  time.Time
+	expiredAt time.Time
+	revokedAt time.Time
+}
+
+func (t RefreshToken) Value() string        { return t.value }
+func (t RefreshToken) FamilyID() string     { return t.familyID }
+func (t RefreshToken) ClientID() string     { return t.clientID }
+func (t RefreshToken) Scope() string        { return t.scope }
+func (t RefreshToken) Owner() TokenOwner    { return t.owner }
+func (t RefreshToken) IssuedAt() time.Time  { return t.issuedAt }
+func (t RefreshToken) ExpiredAt() time.Time { return t.expiredAt }
+func (t RefreshToken) RevokedAt() time.Time { return t.revokedAt }
+func (t RefreshToken) IsRevoked() bool      { return !t.revokedAt.IsZero() }
+
+func NewToken(id, refresh string, expiredAt time.Time) Token {
+	return Token{idToken: id, refresh: refresh, expiredAt: expiredAt}
+}
 
 type Token struct {
-	idToken string
-	refresh string
+	idToken   string
+	refresh   string
+	expiredAt time.Time
 }
 
-func (t Token) IDToken() string { return t.idToken }
-func (t Token) Refresh() string { return t.refresh }
+func (t Token) IDToken() string      { return t.idToken }
+func (t Token) Refresh() string      { return t.refresh }
+func (t Token) ExpiredAt() time.Time { return t.expiredAt }
 
-func NewTokenOwner(id string, email string) TokenOwner { return TokenOwner{id: id, email: email} }
+func NewTokenOwner(orgID, id, email string) TokenOwner {
+	return TokenOwner{orgID: orgID, id: id, email: email}
+}
 
 type TokenOwner struct {
+	orgID string
 	id    string
 	email string
 }
 
-func (o TokenOwner) ID() string    { return o.id }
-func (o TokenOwner) Email() string { return o.email }
+func (o TokenOwner) OrganizationID() string { return o.orgID }
+func (o TokenOwner) ID() string             { return o.id }
+func (o TokenOwner) Email() string          { return o.email }
+
+// Fingerprint トークンそのものを見せずに識別するためのハッシュ値
+func Fingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+)
+
+const (
+	CodeChallengeMethodS256 = "S256"
+)
+
+type AuthorizationCodeAccessor interface {
+	Insert(AuthorizationCode) (string, error)
+	Consume(string, time.Time) (*AuthorizationCode, error)
+}
+
+func NewAuthorizationRequest(clientID, redirectURI, scope, codeChallenge string) AuthorizationRequest {
+	return AuthorizationRequest{
+		clientID:      clientID,
+		redirectURI:   redirectURI,
+		scope:         scope,
+		codeChallenge: codeChallenge,
+	}
+}
+
+// AuthorizationRequest 認可エンドポイントで受け付けたクライアントからの要求
+type AuthorizationRequest struct {
+	clientID      string
+	redirectURI   string
+	scope         string
+	codeChallenge string
+}
+
+func (r AuthorizationRequest) ClientID() string      { return r.clientID }
+func (r AuthorizationRequest) RedirectURI() string   { return r.redirectURI }
+func (r AuthorizationRequest) Scope() string         { return r.scope }
+func (r AuthorizationRequest) CodeChallenge() string { return r.codeChallenge }
+
+func NewAuthorizationCode(
+	code, accountID, clientID, redirectURI, scope, codeChallenge string, expiredAt time.Time,
+) AuthorizationCode {
+	return AuthorizationCode{
+		code:          code,
+		accountID:     accountID,
+		clientID:      clientID,
+		redirectURI:   redirectURI,
+		scope:         scope,
+		codeChallenge: codeChallenge,
+		expiredAt:     expiredAt,
+	}
+}
+
+// AuthorizationCode 認可コードフローでトークンと交換する一時的なコード
+type AuthorizationCode struct {
+	code          string
+	accountID     string
+	clientID      string
+	redirectURI   string
+	scope         string
+	codeChallenge string
+	expiredAt     time.Time
+}
+
+func (c AuthorizationCode) Code() string          { return c.code }
+func (c AuthorizationCode) AccountID() string     { return c.accountID }
+func (c AuthorizationCode) ClientID() string      { return c.clientID }
+func (c AuthorizationCode) RedirectURI() string   { return c.redirectURI }
+func (c AuthorizationCode) Scope() string         { return c.scope }
+func (c AuthorizationCode) CodeChallenge() string { return c.codeChallenge }
+func (c AuthorizationCode) ExpiredAt() time.Time  { return c.expiredAt }
+
+// MatchVerifier PKCE(S256)のcode_verifierが認可リクエスト時のcode_challengeと一致するか検証する
+func (c AuthorizationCode) MatchVerifier(verifier string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(c.codeChallenge)) == 1
+}
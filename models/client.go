@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+)
+
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+type ClientAccessor interface {
+	Find(string) (*Client, error)
+	Insert(Client) (*Client, error)
+}
+
+func NewClient(id, name, secret string, redirectURIs, grantTypes, scopes []string) Client {
+	return Client{
+		id:           id,
+		name:         name,
+		secret:       secret,
+		redirectURIs: redirectURIs,
+		grantTypes:   grantTypes,
+		scopes:       scopes,
+	}
+}
+
+// Client トークンの発行を受けるアプリケーション
+// secretが空のクライアントはシークレットを保持できない公開クライアントとして扱う
+type Client struct {
+	id           string
+	name         string
+	secret       string
+	redirectURIs []string
+	grantTypes   []string
+	scopes       []string
+}
+
+func (c Client) ID() string             { return c.id }
+func (c Client) Name() string           { return c.name }
+func (c Client) Secret() string         { return c.secret }
+func (c Client) RedirectURIs() []string { return c.redirectURIs }
+func (c Client) GrantTypes() []string   { return c.grantTypes }
+func (c Client) Scopes() []string       { return c.scopes }
+func (c Client) IsPublic() bool         { return c.secret == "" }
+
+func (c Client) AllowsRedirectURI(uri string) bool { return contains(c.redirectURIs, uri) }
+func (c Client) AllowsGrant(grantType string) bool { return contains(c.grantTypes, grantType) }
+
+// AllowsScope スペース区切りで要求されたスコープが全て許可されているか判定する
+func (c Client) AllowsScope(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if !contains(c.scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailVerificationPurpose 同じ秘密鍵で署名する他の値と区別するため、署名の対象に含める
+const emailVerificationPurpose = "email_verification"
+
+func NewEmailVerification(accountID, email string, expiredAt time.Time) EmailVerification {
+	return EmailVerification{accountID: accountID, email: email, expiredAt: expiredAt}
+}
+
+// EmailVerification 確認メールのリンクに含める署名付きの値
+// 署名時のメールアドレスを含め、メールアドレスを変更した後は以前のリンクで確認できないようにする
+type EmailVerification struct {
+	accountID string
+	email     string
+	expiredAt time.Time
+}
+
+func (v EmailVerification) AccountID() string    { return v.accountID }
+func (v EmailVerification) Email() string        { return v.email }
+func (v EmailVerification) ExpiredAt() time.Time { return v.expiredAt }
+
+func (v EmailVerification) Sign(secret string) string {
+	payload := base64.RawURLEncoding.EncodeToString(
+		[]byte(strings.Join([]string{v.accountID, v.email, strconv.FormatInt(v.expiredAt.Unix(), 10)}, "\n")),
+	)
+	return payload + "." + signEmailVerification(payload, secret)
+}
+
+// ParseEmailVerification 署名を検証して復元する。有効期限は呼び出し側で確認する
+func ParseEmailVerification(token, secret string) (*EmailVerification, bool) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signEmailVerification(payload, secret))) {
+		return nil, false
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+	fields := strings.Split(string(decoded), "\n")
+	if len(fields) != 3 {
+		return nil, false
+	}
+	expiredAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	verification := NewEmailVerification(fields[0], fields[1], time.Unix(expiredAt, 0))
+	return &verification, true
+}
+
+func signEmailVerification(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(emailVerificationPurpose + "." + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
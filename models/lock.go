@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Locker 複数のインスタンスで同じ処理を同時に実行しないための排他制御
+// TryLockはロックを待たず、取得できた場合のみ解放する関数を返す
+// Lockは指定した時間までロックを待ち、取得できなかった場合はエラーを返す
+type Locker interface {
+	TryLock(string) (func(), bool, error)
+	Lock(string, time.Duration) (func(), error)
+}
@@ -0,0 +1,20 @@
+package models
+
+type Mailer interface {
+	Send(Mail) error
+}
+
+func NewMail(to, subject, body string) Mail {
+	return Mail{to: to, subject: subject, body: body}
+}
+
+// Mail 本文はプレーンテキストとする
+type Mail struct {
+	to      string
+	subject string
+	body    string
+}
+
+func (m Mail) To() string      { return m.to }
+func (m Mail) Subject() string { return m.subject }
+func (m Mail) Body() string    { return m.body }
@@ -0,0 +1,29 @@
+package models
+
+// DefaultOrganizationID テナントを指定しないリクエストと、テナント導入前に登録されたユーザが属する組織
+const DefaultOrganizationID = "00000000-0000-0000-0000-000000000000"
+
+func NewOrganization(id, slug, name, host string) Organization {
+	return Organization{id: id, slug: slug, name: name, host: host}
+}
+
+// Organization ユーザとトークンを分離するテナント
+// slugはパスのプレフィックス、hostはHostヘッダからテナントを特定するために使う
+type Organization struct {
+	id   string
+	slug string
+	name string
+	host string
+}
+
+func (o Organization) ID() string   { return o.id }
+func (o Organization) Slug() string { return o.slug }
+func (o Organization) Name() string { return o.name }
+func (o Organization) Host() string { return o.host }
+
+type OrganizationAccessor interface {
+	Find(string) (*Organization, error)
+	FindBySlug(string) (*Organization, error)
+	FindByHost(string) (*Organization, error)
+	Insert(Organization) (*Organization, error)
+}
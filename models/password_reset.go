@@ -0,0 +1,36 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// HashPasswordResetToken 十分な長さの乱数から生成するため、照合できるよう塩を使わずにハッシュ化する
+func HashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewPasswordResetToken(hash, accountID string, expiredAt time.Time) PasswordResetToken {
+	return PasswordResetToken{hash: hash, accountID: accountID, expiredAt: expiredAt}
+}
+
+// PasswordResetToken メールで送信したパスワード再設定用のトークン
+// トークンそのものは送信時にのみ扱い、ハッシュ値のみ保存する
+type PasswordResetToken struct {
+	hash      string
+	accountID string
+	expiredAt time.Time
+}
+
+func (t PasswordResetToken) Hash() string         { return t.hash }
+func (t PasswordResetToken) AccountID() string    { return t.accountID }
+func (t PasswordResetToken) ExpiredAt() time.Time { return t.expiredAt }
+
+type PasswordResetTokenAccessor interface {
+	Insert(PasswordResetToken) error
+	Consume(string, time.Time) (*PasswordResetToken, error)
+	DeleteByOwner(string) error
+	DeleteExpired(time.Time, int) (int64, error)
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// PersonalAccessTokenPrefix IDトークンと区別するため、パーソナルアクセストークンは必ずこの接頭辞から始まる
+const PersonalAccessTokenPrefix = "pat_"
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// HashPersonalAccessToken 十分な長さの乱数から生成するため、照合できるよう塩を使わずにハッシュ化する
+func HashPersonalAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewPersonalAccessToken(
+	id, orgID, ownerID, name, scope string, issuedAt, expiredAt, revokedAt time.Time,
+) PersonalAccessToken {
+	return PersonalAccessToken{
+		id:        id,
+		orgID:     orgID,
+		ownerID:   ownerID,
+		name:      name,
+		scope:     scope,
+		issuedAt:  issuedAt,
+		expiredAt: expiredAt,
+		revokedAt: revokedAt,
+	}
+}
+
+// PersonalAccessToken スクリプトやCIから利用する、利用者が発行した長期間有効なトークン
+// トークンそのものは発行時にのみ返し、ハッシュ値のみ保存する
+type PersonalAccessToken struct {
+	id        string
+	orgID     string
+	ownerID   string
+	name      string
+	scope     string
+	issuedAt  time.Time
+	expiredAt time.Time
+	revokedAt time.Time
+}
+
+func (t PersonalAccessToken) ID() string             { return t.id }
+func (t PersonalAccessToken) OrganizationID() string { return t.orgID }
+func (t PersonalAccessToken) OwnerID() string        { return t.ownerID }
+func (t PersonalAccessToken) Name() string           { return t.name }
+func (t PersonalAccessToken) Scope() string          { return t.scope }
+func (t PersonalAccessToken) IssuedAt() time.Time    { return t.issuedAt }
+func (t PersonalAccessToken) ExpiredAt() time.Time   { return t.expiredAt }
+func (t PersonalAccessToken) RevokedAt() time.Time   { return t.revokedAt }
+func (t PersonalAccessToken) IsRevoked() bool        { return !t.revokedAt.IsZero() }
+
+type PersonalAccessTokenAccessor interface {
+	Insert(PersonalAccessToken, string) (*PersonalAccessToken, error)
+	FindByHash(string) (*PersonalAccessToken, error)
+	ListByOwner(string, time.Time) ([]PersonalAccessToken, error)
+	Revoke(string, string, time.Time) error
+	RevokeByOwner(string, time.Time) error
+}
@@ -0,0 +1,48 @@
+package models
+
+// ルートが宣言する操作の種類
+const (
+	ActionList   = "list"
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// ルートが宣言する操作対象
+const (
+	ResourceUser         = "user"
+	ResourceSession      = "session"
+	ResourceRefreshToken = "refresh_token"
+	ResourceClient       = "client"
+	ResourceSigningKey   = "signing_key"
+	ResourceOrganization = "organization"
+	ResourcePAT          = "personal_access_token"
+)
+
+func NewPolicyRequest(principal Principal, action, resource, owner string) PolicyRequest {
+	return PolicyRequest{principal: principal, action: action, resource: resource, owner: owner}
+}
+
+// PolicyRequest 主体が操作対象に対して行おうとしている操作
+// ownerは操作対象の持ち主で、特定のユーザに属さない場合は空文字となる
+type PolicyRequest struct {
+	principal Principal
+	action    string
+	resource  string
+	owner     string
+}
+
+func (r PolicyRequest) Principal() Principal { return r.principal }
+func (r PolicyRequest) Action() string       { return r.action }
+func (r PolicyRequest) Resource() string     { return r.resource }
+func (r PolicyRequest) Owner() string        { return r.owner }
+
+// IsOwner 主体が操作対象の持ち主であるか判定する
+func (r PolicyRequest) IsOwner() bool {
+	return r.owner != "" && r.owner == r.principal.Subject()
+}
+
+type PolicyEvaluator interface {
+	Evaluate(PolicyRequest) bool
+}
@@ -0,0 +1,55 @@
+package models
+
+func NewPrincipal(
+	orgID, subject, email string, scopes []string, tokenID string, roles []string,
+) Principal {
+	return Principal{
+		orgID:   orgID,
+		subject: subject,
+		email:   email,
+		scopes:  scopes,
+		tokenID: tokenID,
+		roles:   roles,
+	}
+}
+
+// Principal 検証済みのトークンから特定したリクエストの主体
+// セッショントークンの場合はscopesとtokenIDを持たない
+type Principal struct {
+	orgID   string
+	subject string
+	email   string
+	scopes  []string
+	tokenID string
+	roles   []string
+	// restricted trueの場合はscopesに含まれる操作のみ許可する
+	restricted bool
+}
+
+func (p Principal) OrganizationID() string { return p.orgID }
+
+func (p Principal) Subject() string  { return p.subject }
+func (p Principal) Email() string    { return p.email }
+func (p Principal) Scopes() []string { return p.scopes }
+func (p Principal) TokenID() string  { return p.tokenID }
+func (p Principal) Roles() []string  { return p.roles }
+
+func (p Principal) HasRole(role string) bool { return contains(p.roles, role) }
+
+// Restrict scopesに含まれる操作のみ許可する主体とする
+func (p Principal) Restrict() Principal {
+	p.restricted = true
+	return p
+}
+
+func (p Principal) IsRestricted() bool { return p.restricted }
+
+// Permits スコープで操作が許可されているか判定する。スコープは "操作対象:操作" の形式とする
+func (p Principal) Permits(action, resource string) bool {
+	if !p.restricted {
+		return true
+	}
+	return contains(p.scopes, ScopeAll) ||
+		contains(p.scopes, resource+":"+action) ||
+		contains(p.scopes, resource+":"+ScopeAll)
+}
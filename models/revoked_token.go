@@ -0,0 +1,11 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedTokenAccessor 有効期限前に失効させたIDトークンのjtiを管理する
+type RevokedTokenAccessor interface {
+	Insert(string, time.Time) error
+	Exists(string) (bool, error)
+}
@@ -0,0 +1,15 @@
+package models
+
+const (
+	RoleAdmin   = "admin"
+	RoleSupport = "support"
+)
+
+// DefinedRoles マイグレーション時に作成するロール
+var DefinedRoles = []string{RoleAdmin, RoleSupport}
+
+type RoleAccessor interface {
+	FindByAccount(string) ([]string, error)
+	Assign(string, string) error
+	Unassign(string, string) error
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"strings"
+)
+
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopeAll     = "*"
+)
+
+// HasScope スペース区切りのgrantedにtargetが含まれるか判定する
+func HasScope(granted, target string) bool {
+	return contains(strings.Fields(granted), target)
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	EventRefreshTokenReuse = "refresh_token_reuse"
+	EventSessionEvicted    = "session_evicted"
+	EventRefreshEvicted    = "refresh_token_evicted"
+	EventSignedOutAll      = "signed_out_everywhere"
+	EventPasswordReset     = "password_reset"
+	EventPasswordChanged   = "password_changed"
+)
+
+type SecurityEventRecorder interface {
+	Record(SecurityEvent) error
+}
+
+func NewSecurityEvent(eventType, accountID, detail string, occurredAt time.Time) SecurityEvent {
+	return SecurityEvent{
+		eventType:  eventType,
+		accountID:  accountID,
+		detail:     detail,
+		occurredAt: occurredAt,
+	}
+}
+
+// SecurityEvent 不正利用の疑いなど、運用者が後から確認すべき出来事
+type SecurityEvent struct {
+	eventType  string
+	accountID  string
+	detail     string
+	occurredAt time.Time
+}
+
+func (e SecurityEvent) Type() string          { return e.eventType }
+func (e SecurityEvent) AccountID() string     { return e.accountID }
+func (e SecurityEvent) Detail() string        { return e.detail }
+func (e SecurityEvent) OccurredAt() time.Time { return e.occurredAt }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+func NewSigningKey(id, algorithm, material string, createdAt, retiredAt time.Time) SigningKey {
+	return SigningKey{
+		id:        id,
+		algorithm: algorithm,
+		material:  material,
+		createdAt: createdAt,
+		retiredAt: retiredAt,
+	}
+}
+
+// SigningKey ローテーションで生成した署名鍵
+// 再起動後も全てのインスタンスで同じ鍵を使うため、暗号化した鍵をmaterialとして保存する
+type SigningKey struct {
+	id        string
+	algorithm string
+	material  string
+	createdAt time.Time
+	// retiredAt 署名に使う鍵はゼロ値。退役した鍵はこの日時まで検証にのみ使う
+	retiredAt time.Time
+}
+
+func (k SigningKey) ID() string           { return k.id }
+func (k SigningKey) Algorithm() string    { return k.algorithm }
+func (k SigningKey) Material() string     { return k.material }
+func (k SigningKey) CreatedAt() time.Time { return k.createdAt }
+func (k SigningKey) RetiredAt() time.Time { return k.retiredAt }
+
+// SigningKeyAccessor ローテーションした署名鍵を管理する
+type SigningKeyAccessor interface {
+	// Rotate 署名に使っている鍵をretiredAtで退役させ、新しい鍵を署名に使う鍵として登録する
+	Rotate(SigningKey, time.Time) error
+	// List 全ての鍵を登録した順に返す
+	List() ([]SigningKey, error)
+}
@@ -1,26 +1,76 @@
 package models
 
-func NewUserAccount(id, email, name, password string) UserAccount {
-	return UserAccount{id: id, email: email, name: name, password: password}
+import (
+	"time"
+)
+
+func NewUserAccount(orgID, id, email, name, password string) UserAccount {
+	return UserAccount{orgID: orgID, id: id, email: email, name: name, password: password}
+}
+
+// NewStoredUserAccount 登録済みのユーザを復元する。passwordにはハッシュ値を渡す
+func NewStoredUserAccount(
+	orgID, id, email, name, hash string, emailVerified, resetRequired bool, generation int,
+	disabledAt, updatedAt time.Time,
+) UserAccount {
+	return UserAccount{
+		orgID:         orgID,
+		id:            id,
+		email:         email,
+		name:          name,
+		password:      hash,
+		emailVerified: emailVerified,
+		resetRequired: resetRequired,
+		generation:    generation,
+		disabledAt:    disabledAt,
+		updatedAt:     updatedAt,
+	}
 }
 
 type UserAccount struct {
-	id       string
-	email    string
-	name     string
-	password string
+	orgID         string
+	id            string
+	email         string
+	name          string
+	password      string
+	emailVerified bool
+	resetRequired bool
+	generation    int
+	disabledAt    time.Time
+	updatedAt     time.Time
 }
 
-func (a UserAccount) ID() string       { return a.id }
-func (a UserAccount) Email() string    { return a.email }
-func (a UserAccount) Name() string     { return a.name }
-func (a UserAccount) Password() string { return a.password }
+func (a UserAccount) ID() string           { return a.id }
+func (a UserAccount) Email() string        { return a.email }
+func (a UserAccount) Name() string         { return a.name }
+func (a UserAccount) Password() string     { return a.password }
+func (a UserAccount) UpdatedAt() time.Time { return a.updatedAt }
+
+func (a UserAccount) OrganizationID() string { return a.orgID }
+
+func (a UserAccount) DisabledAt() time.Time { return a.disabledAt }
+func (a UserAccount) IsDisabled() bool      { return !a.disabledAt.IsZero() }
+
+// EmailVerified 確認メールのリンクからメールアドレスの所有を確認済みか。メールアドレスを変更すると未確認に戻る
+func (a UserAccount) EmailVerified() bool { return a.emailVerified }
+
+// ResetRequired 管理者が作成したユーザなど、利用者がパスワードを設定するまでログインさせない
+func (a UserAccount) ResetRequired() bool { return a.resetRequired }
+
+// TokenGeneration 全ての端末からログアウトするたびに増やし、それ以前に発行したIDトークンを無効にする
+func (a UserAccount) TokenGeneration() int { return a.generation }
 
 type UserAccountAccessor interface {
 	Find(string) (*UserAccount, error)
-	FindByEmail(string) (*UserAccount, error)
-	List() ([]UserAccount, error)
-	Insert(string, string, string, string) (*UserAccount, error)
+	FindByEmail(string, string) (*UserAccount, error)
+	List(string) ([]UserAccount, error)
+	Insert(string, string, string, string, string) (*UserAccount, error)
 	Update(UserAccount) (*UserAccount, error)
+	UpdatePassword(string, string) error
 	Delete(string) error
+	RequirePasswordReset(string) error
+	Disable(string, time.Time) error
+	Enable(string) error
+	IncrementTokenGeneration(string) error
+	VerifyEmail(string) error
 }
@@ -0,0 +1,18 @@
+package models
+
+func NewUserInfo(account UserAccount, scope string) UserInfo {
+	return UserInfo{account: account, scope: scope}
+}
+
+// UserInfo IDトークンの持ち主と、そのトークンに許可されたスコープ
+type UserInfo struct {
+	account UserAccount
+	scope   string
+}
+
+func (i UserInfo) Account() UserAccount { return i.account }
+
+// Allows スコープを持たないトークンは自サービス向けに発行したものとして全ての属性を許可する
+func (i UserInfo) Allows(scope string) bool {
+	return i.scope == "" || HasScope(i.scope, scope)
+}
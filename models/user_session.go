@@ -4,27 +4,72 @@ import (
 	"time"
 )
 
-func NewSession(owner, token string, expiredAt time.Time) Session {
+// NewSession expiredAtは無操作で失効する期限、absoluteExpiredAtは延長できる上限
+// ipとuserAgentはログインした端末を利用者が見分けるために記録する
+func NewSession(owner, token, ip, userAgent string, expiredAt, absoluteExpiredAt time.Time) Session {
 	return Session{
-		owner:     owner,
-		token:     token,
-		expiredAt: expiredAt,
+		owner:      owner,
+		token:      token,
+		ip:         ip,
+		userAgent:  userAgent,
+		expiredAt:  expiredAt,
+		absoluteAt: absoluteExpiredAt,
+	}
+}
+
+func NewStoredSession(
+	owner, token, ip, userAgent string, createdAt, lastSeenAt, expiredAt, absoluteExpiredAt time.Time,
+) Session {
+	return Session{
+		owner:      owner,
+		token:      token,
+		ip:         ip,
+		userAgent:  userAgent,
+		createdAt:  createdAt,
+		lastSeenAt: lastSeenAt,
+		expiredAt:  expiredAt,
+		absoluteAt: absoluteExpiredAt,
 	}
 }
 
 type Session struct {
-	owner     string
-	token     string
-	expiredAt time.Time
+	owner      string
+	token      string
+	ip         string
+	userAgent  string
+	createdAt  time.Time
+	lastSeenAt time.Time
+	expiredAt  time.Time
+	absoluteAt time.Time
 }
 
 func (s Session) Owner() string        { return s.owner }
 func (s Session) Token() string        { return s.token }
+func (s Session) CreatedAt() time.Time { return s.createdAt }
 func (s Session) ExpiredAt() time.Time { return s.expiredAt }
 
+func (s Session) IPAddress() string            { return s.ip }
+func (s Session) UserAgent() string            { return s.userAgent }
+func (s Session) LastSeenAt() time.Time        { return s.lastSeenAt }
+func (s Session) AbsoluteExpiredAt() time.Time { return s.absoluteAt }
+
+// Slide 無操作の期限をnowから延長したセッションを返す。延長できる上限を超えることはない
+func (s Session) Slide(now time.Time, idle time.Duration) Session {
+	s.expiredAt = now.Add(idle)
+	if s.expiredAt.After(s.absoluteAt) {
+		s.expiredAt = s.absoluteAt
+	}
+	return s
+}
+
 type UserSessionAccessor interface {
 	Register(Session) (string, error)
 	Verify(string) error
-	FindOwner(string) (string, error)
+	FindOwner(string, time.Time) (*Session, error)
+	Extend(string, time.Time, time.Time) error
 	Delete(string, string) error
+	ListByOwner(string) ([]Session, error)
+	DeleteByOwner(string) error
+	DeleteOthers(string, string) error
+	DeleteExpired(time.Time, int) (int64, error)
 }
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"auth-test/models"
+)
+
+func NewUserAdministration(
+	userAccountRepo models.UserAccountAccessor,
+	userSessionRepo models.UserSessionAccessor,
+	tokenRepo models.TokenAccessor,
+	personalTokenRepo models.PersonalAccessTokenAccessor,
+) UserAdministration {
+	return UserAdministration{
+		userAccountRepo:   userAccountRepo,
+		userSessionRepo:   userSessionRepo,
+		tokenRepo:         tokenRepo,
+		personalTokenRepo: personalTokenRepo,
+	}
+}
+
+// UserAdministration 管理者が他のユーザに対して行う操作
+type UserAdministration struct {
+	userAccountRepo   models.UserAccountAccessor
+	userSessionRepo   models.UserSessionAccessor
+	tokenRepo         models.TokenAccessor
+	personalTokenRepo models.PersonalAccessTokenAccessor
+}
+
+// Create パスワードを指定しない場合はランダムなパスワードで作成し、利用者に再設定させる
+func (a UserAdministration) Create(account models.UserAccount) (*models.UserAccount, error) {
+	password := account.Password()
+	resetRequired := password == ""
+	if resetRequired {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, NewApplicationErr(FailedCreateUser, NewApplicationErr(InternalServerErr, err))
+		}
+		password = base64.RawURLEncoding.EncodeToString(random)
+	}
+
+	created, err := a.userAccountRepo.Insert(
+		account.OrganizationID(), account.ID(), account.Email(), account.Name(), password,
+	)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateUser, err)
+	}
+
+	if resetRequired {
+		if err = a.userAccountRepo.RequirePasswordReset(created.ID()); err != nil {
+			return nil, NewApplicationErr(FailedCreateUser, err)
+		}
+		if created, err = a.userAccountRepo.Find(created.ID()); err != nil {
+			return nil, NewApplicationErr(FailedCreateUser, err)
+		}
+	}
+	return created, nil
+}
+
+// Disable ユーザを無効化し、ログイン中のセッションとリフレッシュトークン、発行済みのIDトークンとパーソナルアクセストークンを失効させる
+func (a UserAdministration) Disable(orgID, id string, now time.Time) error {
+	if _, err := findInOrganization(a.userAccountRepo, orgID, id); err != nil {
+		return NewApplicationErr(FailedDisableUser, err)
+	}
+
+	if err := a.userAccountRepo.Disable(id, now); err != nil {
+		return NewApplicationErr(FailedDisableUser, err)
+	}
+
+	if err := a.signOut(id, now); err != nil {
+		return NewApplicationErr(FailedDisableUser, err)
+	}
+	return nil
+}
+
+func (a UserAdministration) Enable(orgID, id string) error {
+	if _, err := findInOrganization(a.userAccountRepo, orgID, id); err != nil {
+		return NewApplicationErr(FailedEnableUser, err)
+	}
+
+	if err := a.userAccountRepo.Enable(id); err != nil {
+		return NewApplicationErr(FailedEnableUser, err)
+	}
+	return nil
+}
+
+func (a UserAdministration) ForceLogout(orgID, id string, now time.Time) error {
+	if _, err := findInOrganization(a.userAccountRepo, orgID, id); err != nil {
+		return NewApplicationErr(FailedForceLogout, err)
+	}
+
+	if err := a.signOut(id, now); err != nil {
+		return NewApplicationErr(FailedForceLogout, err)
+	}
+	return nil
+}
+
+func (a UserAdministration) Sessions(orgID, id string) ([]models.Session, error) {
+	if _, err := findInOrganization(a.userAccountRepo, orgID, id); err != nil {
+		return nil, NewApplicationErr(FailedListSession, err)
+	}
+
+	sessions, err := a.userSessionRepo.ListByOwner(id)
+	if err != nil {
+		return nil, NewApplicationErr(FailedListSession, err)
+	}
+	return sessions, nil
+}
+
+func (a UserAdministration) RefreshTokens(orgID, id string) ([]models.RefreshToken, error) {
+	if _, err := findInOrganization(a.userAccountRepo, orgID, id); err != nil {
+		return nil, NewApplicationErr(FailedListSession, err)
+	}
+
+	tokens, err := a.tokenRepo.ListByOwner(id)
+	if err != nil {
+		return nil, NewApplicationErr(FailedListSession, err)
+	}
+	return tokens, nil
+}
+
+func (a UserAdministration) signOut(id string, now time.Time) error {
+	return signOutEverywhere(a.userAccountRepo, a.userSessionRepo, a.tokenRepo, a.personalTokenRepo, id, now)
+}
@@ -1,43 +1,91 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"auth-test/models"
 )
 
+const (
+	TokenTypeAccessToken  = "access_token"
+	TokenTypeRefreshToken = "refresh_token"
+)
+
 type Authorizer interface {
-	Claim(string, string, string, time.Time) (*models.Token, error)
-	Refresh(string, string, time.Time) (*models.Token, error)
-	Verify(string) error
+	Claim(string, string, string, string, time.Time) (*models.Token, error)
+	Refresh(string, string, string, string, string, time.Time) (*models.Token, error)
+	VerifyAuthorizationRequest(models.AuthorizationRequest) error
+	Authorize(string, string, string, string, models.AuthorizationRequest, time.Time) (string, error)
+	Exchange(string, string, string, string, string, string, string, time.Time) (*models.Token, error)
+	ClientCredentials(string, string, string, string, time.Time) (*models.Token, error)
+	Revoke(string, string, string, string, time.Time) error
+	Introspect(string, string, string, string, string, time.Time) (*models.Introspection, error)
+	UserInfo(string, string) (*models.UserInfo, error)
+	Verify(string, string) (*models.Principal, error)
+	PublicKeys() []models.PublicKey
+	RotateKey(time.Time) (string, error)
 }
 
 func NewTokenAuthorization(
 	authorizer models.Authorizer,
 	tokenRepo models.TokenAccessor,
+	revokedRepo models.RevokedTokenAccessor,
+	codeRepo models.AuthorizationCodeAccessor,
+	clientRepo models.ClientAccessor,
 	userAccountRepo models.UserAccountAccessor,
+	roleRepo models.RoleAccessor,
+	personalTokenRepo models.PersonalAccessTokenAccessor,
+	eventRecorder models.SecurityEventRecorder,
+	limit SessionLimit,
+	signIn SignInPolicy,
 	refreshExpiration time.Duration,
 	accessExpiration time.Duration,
+	codeExpiration time.Duration,
 ) TokenAuthorization {
 	return TokenAuthorization{
 		authorizer:        authorizer,
 		tokenRepo:         tokenRepo,
+		revokedRepo:       revokedRepo,
+		codeRepo:          codeRepo,
+		clientRepo:        clientRepo,
 		userAccountRepo:   userAccountRepo,
+		roleRepo:          roleRepo,
+		personalTokenRepo: personalTokenRepo,
+		eventRecorder:     eventRecorder,
+		limit:             limit,
+		signIn:            signIn,
 		refreshExpiration: refreshExpiration,
 		accessExpiration:  accessExpiration,
+		codeExpiration:    codeExpiration,
 	}
 }
 
 type TokenAuthorization struct {
 	authorizer        models.Authorizer
 	tokenRepo         models.TokenAccessor
+	revokedRepo       models.RevokedTokenAccessor
+	codeRepo          models.AuthorizationCodeAccessor
+	clientRepo        models.ClientAccessor
 	userAccountRepo   models.UserAccountAccessor
+	roleRepo          models.RoleAccessor
+	personalTokenRepo models.PersonalAccessTokenAccessor
+	eventRecorder     models.SecurityEventRecorder
+	limit             SessionLimit
+	signIn            SignInPolicy
 	refreshExpiration time.Duration
 	accessExpiration  time.Duration
+	codeExpiration    time.Duration
 }
 
-func (a TokenAuthorization) Claim(email, password, newRefreshToken string, now time.Time) (*models.Token, error) {
-	account, err := a.userAccountRepo.FindByEmail(email)
+func (a TokenAuthorization) Claim(
+	orgID, email, password, newRefreshToken string, now time.Time,
+) (*models.Token, error) {
+	account, err := a.userAccountRepo.FindByEmail(orgID, email)
 	if err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
@@ -46,53 +94,585 @@ func (a TokenAuthorization) Claim(email, password, newRefreshToken string, now t
 	if err = hash.MatchWith(password); err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
+	if err = a.signIn.check(*account); err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
 
-	refreshToken, err := a.tokenRepo.Insert(models.NewRefreshTokenInput(
-		account.ID(), newRefreshToken, now.Add(a.refreshExpiration),
-	))
+	release, err := a.limit.lock(account.ID())
 	if err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
+	defer release()
 
-	accessToken, err := a.authorizer.Sign(models.NewAccessTokenInput(
-		account.ID(), account.Email(), now, now.Add(a.accessExpiration),
-	))
-	if err != nil {
+	if err = a.evict(account.ID(), now); err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
 
-	response := models.NewToken(accessToken, refreshToken)
-	return &response, nil
+	return a.issue(orgID, account.ID(), account.Email(), "", "", uuid.New().String(), newRefreshToken, now)
 }
 
-func (a TokenAuthorization) Refresh(newRefreshToken, oldRefreshToken string, now time.Time) (*models.Token, error) {
-	owner, err := a.tokenRepo.FindOwner(oldRefreshToken, now.UTC())
+// Refresh 提示されたリフレッシュトークンを失効させ、同じファミリーの新しいトークンを発行する
+// 失効済みのトークンが提示された場合は盗用とみなし、ファミリー全体を失効させる
+// クライアントに発行したトークンは、発行先のクライアントとして認証した場合のみ更新できる
+func (a TokenAuthorization) Refresh(
+	orgID, clientID, clientSecret, newRefreshToken, oldRefreshToken string, now time.Time,
+) (*models.Token, error) {
+	if clientID != "" {
+		if _, err := a.authenticateClient(clientID, clientSecret); err != nil {
+			return nil, NewApplicationErr(FailedCreateToken, err)
+		}
+	}
+
+	current, err := a.tokenRepo.Find(oldRefreshToken)
 	if err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
 
-	refreshToken, err := a.tokenRepo.Insert(models.NewRefreshTokenInput(
-		owner.ID(), newRefreshToken, now.Add(a.refreshExpiration),
+	// 別の組織や別のクライアントのトークンは存在しないものとして扱い、ファミリーの失効も行わない
+	if current.Owner().OrganizationID() != orgID {
+		return nil, NewApplicationErr(FailedCreateToken, NewApplicationErr(NoTokenRecord, errors.New(orgID)))
+	}
+	if current.ClientID() != clientID {
+		return nil, NewApplicationErr(FailedCreateToken, NewApplicationErr(ForeignRefreshToken, errors.New(clientID)))
+	}
+
+	if current.IsRevoked() {
+		return nil, a.detectReuse(*current, now)
+	}
+
+	if !now.Before(current.ExpiredAt()) {
+		return nil, NewApplicationErr(
+			FailedCreateToken, NewApplicationErr(ExpiredToken, fmt.Errorf("有効期限: %s", current.ExpiredAt())),
+		)
+	}
+
+	if err = a.tokenRepo.Revoke(current.Value(), now.UTC()); err != nil {
+		// 同時に同じトークンで更新された場合も再利用として扱う
+		if errors.Is(err, RevokedToken) {
+			return nil, a.detectReuse(*current, now)
+		}
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	owner := current.Owner()
+	return a.issue(
+		orgID, owner.ID(), owner.Email(), current.ClientID(), current.Scope(), current.FamilyID(), newRefreshToken,
+		now,
+	)
+}
+
+func (a TokenAuthorization) detectReuse(token models.RefreshToken, now time.Time) error {
+	if err := a.tokenRepo.RevokeFamily(token.FamilyID(), now.UTC()); err != nil {
+		return NewApplicationErr(FailedCreateToken, err)
+	}
+
+	// 失効させていないトークンもあるため、イベントにはフィンガープリントのみ記録する
+	event := models.NewSecurityEvent(
+		models.EventRefreshTokenReuse,
+		token.Owner().ID(),
+		fmt.Sprintf(
+			"token: %s, family: %s, client: %s",
+			models.Fingerprint(token.Value()), models.Fingerprint(token.FamilyID()), token.ClientID(),
+		),
+		now,
+	)
+	if err := a.eventRecorder.Record(event); err != nil {
+		return NewApplicationErr(FailedCreateToken, err)
+	}
+
+	return NewApplicationErr(
+		FailedCreateToken,
+		NewApplicationErr(ReusedRefreshToken, fmt.Errorf("family: %s", models.Fingerprint(token.FamilyID()))),
+	)
+}
+
+// evict 同時ログイン数の上限に達している場合、最後に更新されてから最も時間が経ったファミリーから失効させる
+// ファミリーごとに未失効のトークンは1つのため、有効なトークンの数をファミリーの数とみなす
+func (a TokenAuthorization) evict(owner string, now time.Time) error {
+	tokens, err := a.tokenRepo.ListByOwner(owner)
+	if err != nil {
+		return err
+	}
+
+	n, err := a.limit.evictions(len(tokens))
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens[:n] {
+		if err = a.tokenRepo.RevokeFamily(token.FamilyID(), now.UTC()); err != nil {
+			return err
+		}
+
+		event := models.NewSecurityEvent(
+			models.EventRefreshEvicted,
+			owner,
+			fmt.Sprintf("family: %s, client: %s", models.Fingerprint(token.FamilyID()), token.ClientID()),
+			now,
+		)
+		if err = a.eventRecorder.Record(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyAuthorizationRequest 登録済みのクライアントとリダイレクトURIか検証する
+// 未登録のリダイレクトURIへ認可コードを渡さないよう、ログイン画面の表示前にも検証する
+func (a TokenAuthorization) VerifyAuthorizationRequest(request models.AuthorizationRequest) error {
+	client, err := a.clientRepo.Find(request.ClientID())
+	if err != nil {
+		return NewApplicationErr(FailedAuthorize, err)
+	}
+
+	if !client.AllowsGrant(models.GrantTypeAuthorizationCode) {
+		return NewApplicationErr(
+			FailedAuthorize, NewApplicationErr(UnauthorizedGrant, errors.New(models.GrantTypeAuthorizationCode)),
+		)
+	}
+
+	if !client.AllowsRedirectURI(request.RedirectURI()) {
+		return NewApplicationErr(
+			FailedAuthorize, NewApplicationErr(MismatchRedirectURI, errors.New(request.RedirectURI())),
+		)
+	}
+
+	if !client.AllowsScope(request.Scope()) {
+		return NewApplicationErr(FailedAuthorize, NewApplicationErr(InvalidScope, errors.New(request.Scope())))
+	}
+
+	return nil
+}
+
+// Authorize 利用者本人のメールアドレスとパスワードを検証し、クライアントに渡す認可コードを発行する
+func (a TokenAuthorization) Authorize(
+	orgID, email, password, code string, request models.AuthorizationRequest, now time.Time,
+) (string, error) {
+	if err := a.VerifyAuthorizationRequest(request); err != nil {
+		return "", err
+	}
+
+	account, err := a.userAccountRepo.FindByEmail(orgID, email)
+	if err != nil {
+		return "", NewApplicationErr(FailedAuthorize, err)
+	}
+
+	hash := models.NewEncryptedPassword(account.Password())
+	if err = hash.MatchWith(password); err != nil {
+		return "", NewApplicationErr(FailedAuthorize, errors.New("パスワードの検証に失敗"))
+	}
+	if err = a.signIn.check(*account); err != nil {
+		return "", NewApplicationErr(FailedAuthorize, err)
+	}
+
+	issued, err := a.codeRepo.Insert(models.NewAuthorizationCode(
+		code, account.ID(), request.ClientID(), request.RedirectURI(), request.Scope(), request.CodeChallenge(),
+		now.Add(a.codeExpiration),
 	))
 	if err != nil {
+		return "", NewApplicationErr(FailedAuthorize, err)
+	}
+
+	return issued, nil
+}
+
+// Exchange 認可コードをPKCEのcode_verifierで検証し、IDトークンとリフレッシュトークンに交換する
+func (a TokenAuthorization) Exchange(
+	orgID, code, clientID, clientSecret, redirectURI, codeVerifier, newRefreshToken string, now time.Time,
+) (*models.Token, error) {
+	if _, err := a.authenticateClient(clientID, clientSecret); err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
 
-	accessToken, err := a.authorizer.Sign(models.NewAccessTokenInput(
-		owner.ID(), owner.Email(), now, now.Add(a.accessExpiration),
-	))
+	authorizationCode, err := a.codeRepo.Consume(code, now.UTC())
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	if authorizationCode.ClientID() != clientID {
+		return nil, NewApplicationErr(FailedCreateToken, NewApplicationErr(MismatchClient, errors.New(clientID)))
+	}
+
+	if authorizationCode.RedirectURI() != redirectURI {
+		return nil, NewApplicationErr(
+			FailedCreateToken, NewApplicationErr(MismatchRedirectURI, errors.New(redirectURI)),
+		)
+	}
+
+	if !authorizationCode.MatchVerifier(codeVerifier) {
+		return nil, NewApplicationErr(
+			FailedCreateToken, NewApplicationErr(InvalidCodeVerifier, errors.New("code_challengeと一致しません")),
+		)
+	}
+
+	account, err := a.userAccountRepo.Find(authorizationCode.AccountID())
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	if account.OrganizationID() != orgID {
+		return nil, NewApplicationErr(FailedCreateToken, NewApplicationErr(MismatchTenant, errors.New(orgID)))
+	}
+
+	// 認可コードを発行した後に無効化やパスワードの再設定を要求された場合は交換させない
+	if err = a.signIn.check(*account); err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	release, err := a.limit.lock(account.ID())
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+	defer release()
+
+	if err = a.evict(account.ID(), now); err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	return a.issue(
+		orgID, account.ID(), account.Email(), authorizationCode.ClientID(), authorizationCode.Scope(),
+		uuid.New().String(), newRefreshToken, now,
+	)
+}
+
+// ClientCredentials クライアント自身を主体とするトークンを発行する
+// 利用者が介在しないため、リフレッシュトークンは発行しない
+// クライアントは組織をまたいで登録されるため、リクエストした組織のトークンとして発行する
+func (a TokenAuthorization) ClientCredentials(
+	orgID, clientID, clientSecret, scope string, now time.Time,
+) (*models.Token, error) {
+	client, err := a.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	if client.IsPublic() || !client.AllowsGrant(models.GrantTypeClientCredentials) {
+		return nil, NewApplicationErr(
+			FailedCreateToken, NewApplicationErr(UnauthorizedGrant, errors.New(models.GrantTypeClientCredentials)),
+		)
+	}
+
+	if scope == "" {
+		scope = strings.Join(client.Scopes(), " ")
+	}
+	if !client.AllowsScope(scope) {
+		return nil, NewApplicationErr(FailedCreateToken, NewApplicationErr(InvalidScope, errors.New(scope)))
+	}
+
+	expiredAt := now.Add(a.accessExpiration)
+	accessToken, err := a.authorizer.Sign(
+		models.NewAccessTokenInput(orgID, client.ID(), "", client.ID(), scope, false, []string{}, 0, now, expiredAt),
+	)
 	if err != nil {
 		return nil, NewApplicationErr(FailedCreateToken, err)
 	}
 
-	response := models.NewToken(accessToken, refreshToken)
+	response := models.NewToken(accessToken, "", expiredAt)
 	return &response, nil
 }
 
-func (a TokenAuthorization) Verify(accessToken string) error {
-	if err := a.authorizer.Verify(accessToken); err != nil {
-		return NewApplicationErr(FailedAuthenticate, err)
+// Verify IDトークンまたはパーソナルアクセストークンを検証し、リクエストの主体を返す
+func (a TokenAuthorization) Verify(orgID, accessToken string) (*models.Principal, error) {
+	if models.IsPersonalAccessToken(accessToken) {
+		principal, err := a.verifyPersonalAccessToken(orgID, accessToken, time.Now().UTC())
+		if err != nil {
+			return nil, NewApplicationErr(FailedAuthenticate, err)
+		}
+		return principal, nil
+	}
+
+	claims, err := a.verify(orgID, accessToken)
+	if err != nil {
+		return nil, NewApplicationErr(FailedAuthenticate, err)
+	}
+
+	principal := models.NewPrincipal(
+		claims.OrganizationID(), claims.Subject(), claims.Email(), strings.Fields(claims.Scope()), claims.TokenID(), claims.Roles(),
+	)
+	// クライアントに発行したトークンは利用者本人の権限を持たず、許可されたスコープの操作のみ行える
+	if claims.ClientID() != "" {
+		principal = principal.Restrict()
+	}
+	return &principal, nil
+}
+
+// Revoke RFC 7009 に従いトークンを失効させる
+// 存在しないトークンや期限切れのトークンは失効済みとみなしてエラーにしない
+func (a TokenAuthorization) Revoke(token, tokenTypeHint, clientID, clientSecret string, now time.Time) error {
+	if clientID != "" {
+		if _, err := a.authenticateClient(clientID, clientSecret); err != nil {
+			return NewApplicationErr(FailedRevokeToken, err)
+		}
+	}
+
+	revokeFuncs := []func(string, string, time.Time) error{a.revokeRefreshToken, a.revokeAccessToken}
+	if tokenTypeHint == TokenTypeAccessToken {
+		revokeFuncs = []func(string, string, time.Time) error{a.revokeAccessToken, a.revokeRefreshToken}
+	}
+
+	for _, revoke := range revokeFuncs {
+		err := revoke(token, clientID, now)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, NoTokenRecord) {
+			return NewApplicationErr(FailedRevokeToken, err)
+		}
 	}
 
 	return nil
 }
+
+// revokeRefreshToken 同じログインから発行されたリフレッシュトークンもまとめて失効させる
+func (a TokenAuthorization) revokeRefreshToken(token, clientID string, now time.Time) error {
+	refreshToken, err := a.tokenRepo.Find(token)
+	if err != nil {
+		return err
+	}
+
+	if refreshToken.ClientID() != "" && refreshToken.ClientID() != clientID {
+		return NewApplicationErr(MismatchClient, errors.New(clientID))
+	}
+
+	return a.tokenRepo.RevokeFamily(refreshToken.FamilyID(), now.UTC())
+}
+
+// revokeAccessToken jtiを持たない、または検証できないIDトークンは失効対象が存在しないものとして扱う
+func (a TokenAuthorization) revokeAccessToken(token, clientID string, now time.Time) error {
+	claims, err := a.authorizer.Verify(token)
+	if err != nil || claims.TokenID() == "" {
+		return NewApplicationErr(NoTokenRecord, errors.New("失効対象のIDトークンが存在しません"))
+	}
+
+	if claims.ClientID() != "" && claims.ClientID() != clientID {
+		return NewApplicationErr(MismatchClient, errors.New(clientID))
+	}
+
+	return a.revokedRepo.Insert(claims.TokenID(), claims.ExpiredAt())
+}
+
+// Introspect RFC 7662 に従いトークンが有効か判定する
+// 無効なトークンの場合はエラーではなくnilを返す
+func (a TokenAuthorization) Introspect(
+	orgID, token, tokenTypeHint, clientID, clientSecret string, now time.Time,
+) (*models.Introspection, error) {
+	client, err := a.authenticateClient(clientID, clientSecret)
+	if err != nil {
+		return nil, NewApplicationErr(FailedIntrospect, err)
+	}
+	if client.IsPublic() {
+		return nil, NewApplicationErr(
+			FailedIntrospect, NewApplicationErr(InvalidClientSecret, errors.New("公開クライアントは利用できません")),
+		)
+	}
+
+	introspectFuncs := []func(string, string, time.Time) (*models.Introspection, error){
+		a.introspectAccessToken, a.introspectRefreshToken,
+	}
+	if tokenTypeHint == TokenTypeRefreshToken {
+		introspectFuncs = []func(string, string, time.Time) (*models.Introspection, error){
+			a.introspectRefreshToken, a.introspectAccessToken,
+		}
+	}
+
+	for _, introspect := range introspectFuncs {
+		result, err := introspect(orgID, token, now)
+		if err == nil {
+			return result, nil
+		}
+		if errors.Is(err, InternalServerErr) {
+			return nil, NewApplicationErr(FailedIntrospect, err)
+		}
+	}
+
+	return nil, nil
+}
+
+// UserInfo IDトークンの持ち主の属性を取得する
+func (a TokenAuthorization) UserInfo(orgID, accessToken string) (*models.UserInfo, error) {
+	claims, err := a.verify(orgID, accessToken)
+	if err != nil {
+		return nil, NewApplicationErr(FailedAuthenticate, err)
+	}
+
+	account, err := a.userAccountRepo.Find(claims.Subject())
+	if err != nil {
+		return nil, NewApplicationErr(FailedShowUser, err)
+	}
+
+	response := models.NewUserInfo(*account, claims.Scope())
+	return &response, nil
+}
+
+func (a TokenAuthorization) introspectAccessToken(
+	orgID, token string, _ time.Time,
+) (*models.Introspection, error) {
+	claims, err := a.verify(orgID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	response := models.NewIntrospection(TokenTypeAccessToken, *claims)
+	return &response, nil
+}
+
+func (a TokenAuthorization) introspectRefreshToken(
+	orgID, token string, now time.Time,
+) (*models.Introspection, error) {
+	refreshToken, err := a.tokenRepo.FindOwner(token, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	owner := refreshToken.Owner()
+	if owner.OrganizationID() != orgID {
+		return nil, NewApplicationErr(MismatchTenant, errors.New(orgID))
+	}
+
+	response := models.NewIntrospection(TokenTypeRefreshToken, models.NewClaims(
+		owner.OrganizationID(), owner.ID(), owner.Email(), refreshToken.ClientID(), refreshToken.Scope(), "", false,
+		[]string{}, 0, refreshToken.IssuedAt(), refreshToken.ExpiredAt(),
+	))
+	return &response, nil
+}
+
+// verify 署名と失効の確認に加え、リクエストした組織で発行されたトークンか確認する
+func (a TokenAuthorization) verify(orgID, accessToken string) (*models.Claims, error) {
+	claims, err := a.authorizer.Verify(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.OrganizationID() != orgID {
+		return nil, NewApplicationErr(MismatchTenant, errors.New(claims.OrganizationID()))
+	}
+
+	if claims.TokenID() != "" {
+		revoked, err := a.revokedRepo.Exists(claims.TokenID())
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, NewApplicationErr(RevokedToken, errors.New(claims.TokenID()))
+		}
+	}
+
+	// 利用者のトークンは全ての端末からログアウトした時点で世代が変わり、それ以前のものは失効する
+	if !claims.IsClient() {
+		account, err := a.userAccountRepo.Find(claims.Subject())
+		if err != nil {
+			return nil, err
+		}
+		if account.TokenGeneration() != claims.Generation() {
+			return nil, NewApplicationErr(RevokedToken, fmt.Errorf("世代: %d", claims.Generation()))
+		}
+	}
+
+	return claims, nil
+}
+
+// verifyPersonalAccessToken スコープに含まれる操作のみ許可する主体を返す
+// ロールは発行時点ではなく、検証の都度取得する
+func (a TokenAuthorization) verifyPersonalAccessToken(
+	orgID, value string, now time.Time,
+) (*models.Principal, error) {
+	token, err := a.personalTokenRepo.FindByHash(models.HashPersonalAccessToken(value))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case token.OrganizationID() != orgID:
+		return nil, NewApplicationErr(MismatchTenant, errors.New(token.OrganizationID()))
+	case token.IsRevoked():
+		return nil, NewApplicationErr(RevokedToken, errors.New(token.ID()))
+	case !now.Before(token.ExpiredAt()):
+		return nil, NewApplicationErr(ExpiredToken, fmt.Errorf("有効期限: %s", token.ExpiredAt()))
+	}
+
+	account, err := a.userAccountRepo.Find(token.OwnerID())
+	if err != nil {
+		return nil, err
+	}
+	if account.IsDisabled() {
+		return nil, NewApplicationErr(DisabledAccount, errors.New(account.ID()))
+	}
+
+	roles, err := a.roleRepo.FindByAccount(account.ID())
+	if err != nil {
+		return nil, err
+	}
+
+	principal := models.NewPrincipal(
+		orgID, account.ID(), account.Email(), strings.Fields(token.Scope()), token.ID(), roles,
+	).Restrict()
+	return &principal, nil
+}
+
+func (a TokenAuthorization) PublicKeys() []models.PublicKey {
+	return a.authorizer.PublicKeys()
+}
+
+func (a TokenAuthorization) RotateKey(now time.Time) (string, error) {
+	keyID, err := a.authorizer.Rotate(now)
+	if err != nil {
+		return "", NewApplicationErr(FailedRotateKey, err)
+	}
+	return keyID, nil
+}
+
+// authenticateClient 機密クライアントはシークレットを検証する。公開クライアントはPKCEで保護する
+func (a TokenAuthorization) authenticateClient(clientID, clientSecret string) (*models.Client, error) {
+	client, err := a.clientRepo.Find(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.IsPublic() {
+		return client, nil
+	}
+
+	hash := models.NewEncryptedPassword(client.Secret())
+	if err = hash.MatchWith(clientSecret); err != nil {
+		return nil, NewApplicationErr(InvalidClientSecret, err)
+	}
+
+	return client, nil
+}
+
+// issue 新しいログインの場合はファミリーIDを新たに生成する
+// ファミリーIDは更新後のトークンにも引き継ぐため、リフレッシュトークンの値は使わない
+func (a TokenAuthorization) issue(
+	orgID, accountID, email, clientID, scope, familyID, newRefreshToken string, now time.Time,
+) (*models.Token, error) {
+	account, err := a.userAccountRepo.Find(accountID)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	// ロールの変更は次にトークンを発行した時点から反映される
+	roles, err := a.roleRepo.FindByAccount(accountID)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	refreshToken, err := a.tokenRepo.Insert(models.NewRefreshTokenInput(
+		accountID, newRefreshToken, familyID, clientID, scope, now.Add(a.refreshExpiration),
+	))
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	expiredAt := now.Add(a.accessExpiration)
+	accessToken, err := a.authorizer.Sign(
+		models.NewAccessTokenInput(
+			orgID, accountID, email, clientID, scope, account.EmailVerified(), roles, account.TokenGeneration(),
+			now, expiredAt,
+		),
+	)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateToken, err)
+	}
+
+	response := models.NewToken(accessToken, refreshToken, expiredAt)
+	return &response, nil
+}
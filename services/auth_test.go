@@ -0,0 +1,414 @@
+package services_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-test/infra/auth"
+	"auth-test/infra/memory"
+	"auth-test/models"
+	"auth-test/services"
+)
+
+const (
+	testEmail        = "user@example.com"
+	testPassword     = "Passw0rd!long"
+	testRedirectURI  = "https://client.example.com/callback"
+	testClientSecret = "client-secret"
+	confidentialID   = "confidential-client"
+	publicID         = "public-client"
+)
+
+// eventRecorder 記録されたセキュリティイベントを検証するため、メモリ上に保持する
+type eventRecorder struct {
+	events []models.SecurityEvent
+}
+
+func (r *eventRecorder) Record(event models.SecurityEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+type fixture struct {
+	service   services.TokenAuthorization
+	accounts  models.UserAccountAccessor
+	accountID string
+	events    *eventRecorder
+	now       time.Time
+}
+
+// newFixture 利用者1人と、機密クライアントと公開クライアントを1つずつ登録したメモリのストアで初期化する
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	store := memory.NewStore()
+	accounts := memory.NewUserAccountRepository(store)
+	clients := memory.NewClientRepository(store)
+
+	account, err := accounts.Insert(models.DefaultOrganizationID, uuid.New().String(), testEmail, "name", testPassword)
+	if err != nil {
+		t.Fatalf("ユーザの登録に失敗: %v", err)
+	}
+	grants := []string{models.GrantTypeAuthorizationCode, models.GrantTypeRefreshToken}
+	for id, secret := range map[string]string{confidentialID: testClientSecret, publicID: ""} {
+		client := models.NewClient(id, id, secret, []string{testRedirectURI}, grants, []string{"openid"})
+		if _, err := clients.Insert(client); err != nil {
+			t.Fatalf("クライアントの登録に失敗: %v", err)
+		}
+	}
+
+	authorizer, err := auth.NewAuthorizer(
+		"http://localhost:8080", auth.AlgorithmHS256, "secret", "", time.Hour, memory.NewSigningKeyRepository(store),
+	)
+	if err != nil {
+		t.Fatalf("署名鍵の生成に失敗: %v", err)
+	}
+	limit, err := services.NewSessionLimit(0, services.LimitPolicyReject, memory.NewLocker())
+	if err != nil {
+		t.Fatalf("同時ログイン数の制限の生成に失敗: %v", err)
+	}
+
+	events := &eventRecorder{}
+	service := services.NewTokenAuthorization(
+		authorizer,
+		memory.NewTokenRepository(store),
+		memory.NewRevokedTokenRepository(store),
+		memory.NewAuthorizationCodeRepository(store),
+		clients,
+		accounts,
+		memory.NewRoleRepository(store),
+		memory.NewPersonalAccessTokenRepository(store),
+		events,
+		limit,
+		services.NewSignInPolicy(false),
+		time.Hour,
+		10*time.Minute,
+		time.Minute,
+	)
+	return fixture{
+		service: service, accounts: accounts, accountID: account.ID(), events: events,
+		now: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// authorize 利用者がログインして、clientIDのクライアントに認可コードを発行する
+func (f fixture) authorize(t *testing.T, clientID, codeChallenge string) string {
+	t.Helper()
+	request := models.NewAuthorizationRequest(clientID, testRedirectURI, "openid", codeChallenge)
+	code, err := f.service.Authorize(
+		models.DefaultOrganizationID, testEmail, testPassword, uuid.New().String(), request, f.now,
+	)
+	if err != nil {
+		t.Fatalf("認可コードの発行に失敗: %v", err)
+	}
+	return code
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func assertErr(t *testing.T, err, want error) {
+	t.Helper()
+	if want == nil {
+		if err != nil {
+			t.Fatalf("予期しないエラー: %v", err)
+		}
+		return
+	}
+	if !errors.Is(err, want) {
+		t.Fatalf("エラーが一致しません: want %v, got %v", want, err)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	tests := []struct {
+		name         string
+		clientID     string
+		clientSecret string
+		redirectURI  string
+		codeVerifier string
+		want         error
+	}{
+		{
+			name: "code_challengeと一致するcode_verifier", clientID: confidentialID, clientSecret: testClientSecret,
+			redirectURI: testRedirectURI, codeVerifier: verifier,
+		},
+		{
+			name: "code_challengeと一致しないcode_verifier", clientID: confidentialID, clientSecret: testClientSecret,
+			redirectURI: testRedirectURI, codeVerifier: verifier + "x", want: services.InvalidCodeVerifier,
+		},
+		{
+			name: "code_challengeそのものをcode_verifierとして提示", clientID: confidentialID,
+			clientSecret: testClientSecret, redirectURI: testRedirectURI, codeVerifier: codeChallenge(verifier),
+			want: services.InvalidCodeVerifier,
+		},
+		{
+			name: "空のcode_verifier", clientID: confidentialID, clientSecret: testClientSecret,
+			redirectURI: testRedirectURI, codeVerifier: "", want: services.InvalidCodeVerifier,
+		},
+		{
+			name: "認可コードを発行していないクライアント", clientID: publicID, redirectURI: testRedirectURI,
+			codeVerifier: verifier, want: services.MismatchClient,
+		},
+		{
+			name: "認可リクエストと異なるリダイレクトURI", clientID: confidentialID, clientSecret: testClientSecret,
+			redirectURI: testRedirectURI + "/other", codeVerifier: verifier, want: services.MismatchRedirectURI,
+		},
+		{
+			name: "誤ったクライアントシークレット", clientID: confidentialID, clientSecret: "wrong",
+			redirectURI: testRedirectURI, codeVerifier: verifier, want: services.InvalidClientSecret,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			code := f.authorize(t, confidentialID, codeChallenge(verifier))
+
+			token, err := f.service.Exchange(
+				models.DefaultOrganizationID, code, tt.clientID, tt.clientSecret, tt.redirectURI, tt.codeVerifier,
+				uuid.New().String(), f.now,
+			)
+			assertErr(t, err, tt.want)
+			if tt.want == nil && (token.IDToken() == "" || token.Refresh() == "") {
+				t.Fatalf("トークンが発行されていません: %+v", token)
+			}
+		})
+	}
+}
+
+func TestExchangeChecksSignInPolicy(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	tests := []struct {
+		name   string
+		change func(f fixture) error
+		want   error
+	}{
+		{
+			name:   "認可コードの発行後に無効化",
+			change: func(f fixture) error { return f.accounts.Disable(f.accountID, f.now) },
+			want:   services.DisabledAccount,
+		},
+		{
+			name:   "認可コードの発行後にパスワードの再設定を要求",
+			change: func(f fixture) error { return f.accounts.RequirePasswordReset(f.accountID) },
+			want:   services.ResetRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			code := f.authorize(t, confidentialID, codeChallenge(verifier))
+			assertErr(t, tt.change(f), nil)
+
+			_, err := f.service.Exchange(
+				models.DefaultOrganizationID, code, confidentialID, testClientSecret, testRedirectURI, verifier,
+				uuid.New().String(), f.now,
+			)
+			assertErr(t, err, tt.want)
+		})
+	}
+}
+
+func TestExchangeConsumesCode(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	f := newFixture(t)
+	code := f.authorize(t, confidentialID, codeChallenge(verifier))
+
+	// 検証に失敗した場合も認可コードは再利用できない
+	_, err := f.service.Exchange(
+		models.DefaultOrganizationID, code, confidentialID, testClientSecret, testRedirectURI, "wrong",
+		uuid.New().String(), f.now,
+	)
+	assertErr(t, err, services.InvalidCodeVerifier)
+	_, err = f.service.Exchange(
+		models.DefaultOrganizationID, code, confidentialID, testClientSecret, testRedirectURI, verifier,
+		uuid.New().String(), f.now,
+	)
+	assertErr(t, err, services.NoAuthorizationCode)
+}
+
+func TestRefreshClient(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	tests := []struct {
+		name         string
+		issuedTo     string
+		clientID     string
+		clientSecret string
+		want         error
+	}{
+		{name: "発行したクライアント", issuedTo: confidentialID, clientID: confidentialID, clientSecret: testClientSecret},
+		{name: "発行した公開クライアント", issuedTo: publicID, clientID: publicID},
+		{name: "Claimで発行したトークン", issuedTo: ""},
+		{
+			name: "クライアントを認証しない", issuedTo: confidentialID, want: services.ForeignRefreshToken,
+		},
+		{
+			name: "誤ったクライアントシークレット", issuedTo: confidentialID, clientID: confidentialID,
+			clientSecret: "wrong", want: services.InvalidClientSecret,
+		},
+		{
+			name: "別のクライアント", issuedTo: confidentialID, clientID: publicID, want: services.ForeignRefreshToken,
+		},
+		{
+			name: "Claimで発行したトークンをクライアントが提示", issuedTo: "", clientID: confidentialID,
+			clientSecret: testClientSecret, want: services.ForeignRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			var (
+				issued       *models.Token
+				err          error
+				issuerSecret string
+			)
+			if tt.issuedTo == confidentialID {
+				issuerSecret = testClientSecret
+			}
+			if tt.issuedTo == "" {
+				issued, err = f.service.Claim(
+					models.DefaultOrganizationID, testEmail, testPassword, uuid.New().String(), f.now,
+				)
+			} else {
+				issued, err = f.service.Exchange(
+					models.DefaultOrganizationID, f.authorize(t, tt.issuedTo, codeChallenge(verifier)), tt.issuedTo,
+					issuerSecret, testRedirectURI, verifier, uuid.New().String(), f.now,
+				)
+			}
+			assertErr(t, err, nil)
+
+			_, err = f.service.Refresh(
+				models.DefaultOrganizationID, tt.clientID, tt.clientSecret, uuid.New().String(), issued.Refresh(),
+				f.now,
+			)
+			assertErr(t, err, tt.want)
+			if tt.want == nil {
+				return
+			}
+
+			// 発行先ではないクライアントからの提示では失効させないため、発行先からは引き続き更新できる
+			if len(f.events.events) != 0 {
+				t.Fatalf("セキュリティイベントが記録されています: %+v", f.events.events)
+			}
+			_, err = f.service.Refresh(
+				models.DefaultOrganizationID, tt.issuedTo, issuerSecret, uuid.New().String(), issued.Refresh(), f.now,
+			)
+			assertErr(t, err, nil)
+		})
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	tests := []struct {
+		name  string
+		reuse func(rotated []string) string
+	}{
+		{name: "最初に発行したトークンを再利用", reuse: func(rotated []string) string { return rotated[0] }},
+		{name: "直前に更新したトークンを再利用", reuse: func(rotated []string) string { return rotated[len(rotated)-2] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			claim := func() string {
+				token, err := f.service.Claim(
+					models.DefaultOrganizationID, testEmail, testPassword, uuid.New().String(), f.now,
+				)
+				assertErr(t, err, nil)
+				return token.Refresh()
+			}
+			refresh := func(value string) (*models.Token, error) {
+				return f.service.Refresh(models.DefaultOrganizationID, "", "", uuid.New().String(), value, f.now)
+			}
+
+			// 更新の都度新しいトークンが発行され、更新前のトークンは失効する
+			rotated := []string{claim()}
+			for i := 0; i < 2; i++ {
+				token, err := refresh(rotated[len(rotated)-1])
+				assertErr(t, err, nil)
+				if token.Refresh() == rotated[len(rotated)-1] {
+					t.Fatalf("同じリフレッシュトークンが発行されています")
+				}
+				rotated = append(rotated, token.Refresh())
+			}
+			other := claim()
+
+			reused := tt.reuse(rotated)
+			_, err := refresh(reused)
+			assertErr(t, err, services.ReusedRefreshToken)
+
+			// 同じファミリーの最新のトークンも失効し、別のログインのファミリーは影響を受けない
+			_, err = refresh(rotated[len(rotated)-1])
+			assertErr(t, err, services.ReusedRefreshToken)
+			_, err = refresh(other)
+			assertErr(t, err, nil)
+
+			if len(f.events.events) == 0 {
+				t.Fatalf("再利用が記録されていません")
+			}
+			event := f.events.events[0]
+			if event.Type() != models.EventRefreshTokenReuse {
+				t.Fatalf("イベントの種類が一致しません: %s", event.Type())
+			}
+			if strings.Contains(event.Detail(), reused) {
+				t.Fatalf("イベントにトークンの値が記録されています: %s", event.Detail())
+			}
+		})
+	}
+}
+
+func TestVerifyRestrictsClientToken(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+	tests := []struct {
+		name     string
+		action   string
+		resource string
+	}{
+		{name: "ユーザの更新", action: models.ActionUpdate, resource: models.ResourceUser},
+		{name: "ユーザの削除", action: models.ActionDelete, resource: models.ResourceUser},
+		{name: "パーソナルアクセストークンの発行", action: models.ActionCreate, resource: models.ResourcePAT},
+	}
+
+	f := newFixture(t)
+	issued, err := f.service.Exchange(
+		models.DefaultOrganizationID, f.authorize(t, confidentialID, codeChallenge(verifier)), confidentialID,
+		testClientSecret, testRedirectURI, verifier, uuid.New().String(), f.now,
+	)
+	assertErr(t, err, nil)
+	delegated, err := f.service.Verify(models.DefaultOrganizationID, issued.IDToken())
+	assertErr(t, err, nil)
+
+	claimed, err := f.service.Claim(models.DefaultOrganizationID, testEmail, testPassword, uuid.New().String(), f.now)
+	assertErr(t, err, nil)
+	firstParty, err := f.service.Verify(models.DefaultOrganizationID, claimed.IDToken())
+	assertErr(t, err, nil)
+
+	if !delegated.IsRestricted() || firstParty.IsRestricted() {
+		t.Fatalf("制限の有無が一致しません: client %t, claim %t", delegated.IsRestricted(), firstParty.IsRestricted())
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if delegated.Permits(tt.action, tt.resource) {
+				t.Fatalf("クライアントに発行したトークンで許可されています: %s %s", tt.action, tt.resource)
+			}
+			if !firstParty.Permits(tt.action, tt.resource) {
+				t.Fatalf("Claimで発行したトークンで拒否されています: %s %s", tt.action, tt.resource)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"auth-test/models"
+)
+
+func NewClientRegistry(repo models.ClientAccessor) ClientRegistry { return ClientRegistry{repo: repo} }
+
+type ClientRegistry struct {
+	repo models.ClientAccessor
+}
+
+func (r ClientRegistry) Register(client models.Client) (*models.Client, error) {
+	registered, err := r.repo.Insert(client)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateClient, err)
+	}
+	return registered, nil
+}
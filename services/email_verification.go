@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"auth-test/models"
+)
+
+func NewEmailVerifier(
+	repo models.UserAccountAccessor, mailer models.Mailer, secret, verifyURL string, expiration time.Duration,
+) EmailVerifier {
+	return EmailVerifier{
+		repo:       repo,
+		mailer:     mailer,
+		secret:     secret,
+		verifyURL:  verifyURL,
+		expiration: expiration,
+	}
+}
+
+// EmailVerifier 署名付きのリンクを送信し、リンクを開いた利用者のメールアドレスを確認済みにする
+// verifyURLにはリンクを受け取る画面のURLを指定し、tokenクエリを付与して送信する
+type EmailVerifier struct {
+	repo       models.UserAccountAccessor
+	mailer     models.Mailer
+	secret     string
+	verifyURL  string
+	expiration time.Duration
+}
+
+// Send 確認済みのユーザには送信しない
+func (v EmailVerifier) Send(account models.UserAccount, now time.Time) error {
+	if account.EmailVerified() {
+		return nil
+	}
+
+	token := models.NewEmailVerification(account.ID(), account.Email(), now.Add(v.expiration)).Sign(v.secret)
+	link, err := url.Parse(v.verifyURL)
+	if err != nil {
+		return NewApplicationErr(FailedSendMail, NewApplicationErr(InternalServerErr, err))
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	mail := models.NewMail(
+		account.Email(),
+		"メールアドレスの確認",
+		fmt.Sprintf(
+			"以下のリンクからメールアドレスを確認してください。\n\n%s\n\nリンクの有効期限は%sです。\n",
+			link.String(), now.Add(v.expiration).Format(time.RFC3339),
+		),
+	)
+	if err = v.mailer.Send(mail); err != nil {
+		return NewApplicationErr(FailedSendMail, err)
+	}
+	return nil
+}
+
+// Resend 登録の有無を推測されないよう、存在しないメールアドレスでもエラーにしない
+// 送信の失敗はエラーとして返すため、呼び出し側でレスポンスに含めないようにする
+func (v EmailVerifier) Resend(orgID, email string, now time.Time) error {
+	account, err := v.repo.FindByEmail(orgID, email)
+	if err != nil {
+		if errors.Is(err, NoUserEmail) {
+			return nil
+		}
+		return NewApplicationErr(FailedSendMail, err)
+	}
+	return v.Send(*account, now)
+}
+
+// Verify リンクはメールを受信できることの確認のため、組織は問わない
+func (v EmailVerifier) Verify(token string, now time.Time) error {
+	verification, ok := models.ParseEmailVerification(token, v.secret)
+	if !ok {
+		return NewApplicationErr(FailedVerifyEmail, NewApplicationErr(InvalidToken, errors.New("署名が一致しません")))
+	}
+	if !now.Before(verification.ExpiredAt()) {
+		return NewApplicationErr(
+			FailedVerifyEmail, NewApplicationErr(ExpiredToken, fmt.Errorf("有効期限: %s", verification.ExpiredAt())),
+		)
+	}
+
+	account, err := v.repo.Find(verification.AccountID())
+	if err != nil {
+		return NewApplicationErr(FailedVerifyEmail, err)
+	}
+	if account.Email() != verification.Email() {
+		return NewApplicationErr(
+			FailedVerifyEmail, NewApplicationErr(InvalidToken, errors.New("メールアドレスが変更されています")),
+		)
+	}
+	if account.EmailVerified() {
+		return nil
+	}
+
+	if err = v.repo.VerifyEmail(account.ID()); err != nil {
+		return NewApplicationErr(FailedVerifyEmail, err)
+	}
+	return nil
+}
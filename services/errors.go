@@ -20,8 +20,41 @@ var (
 	InvalidClaim        = errors.New("無効なペイロードです")
 	FailedSingedToken   = errors.New("トークンの署名に失敗しました")
 	InvalidIssued       = errors.New("発行時期が無効なトークンです")
+	InvalidIssuer       = errors.New("発行者が無効なトークンです")
 	TooLongPassword     = errors.New("パスワードを72文字以内にしてください")
 	InvalidUUIDFormat   = errors.New("無効なUUIDです")
+	InvalidSigningKey   = errors.New("署名鍵が不正です")
+	UnknownAlgorithm    = errors.New("未対応の署名アルゴリズムです")
+	UnknownSigningKey   = errors.New("署名鍵が見つかりません")
+	NoAuthorizationCode = errors.New("認可コードは存在しません")
+	InvalidCodeVerifier = errors.New("コード検証子が一致しません")
+	MismatchClient      = errors.New("クライアントが一致しません")
+	MismatchRedirectURI = errors.New("リダイレクトURIが一致しません")
+	MismatchPassword    = errors.New("現在のパスワードが一致しません")
+	NoClientRecord      = errors.New("クライアントは存在しません")
+	DuplicateClient     = errors.New("クライアントが既に存在します")
+	InvalidClientSecret = errors.New("クライアントシークレットが一致しません")
+	UnauthorizedGrant   = errors.New("クライアントに許可されていないグラントです")
+	InvalidScope        = errors.New("クライアントに許可されていないスコープです")
+	RevokedToken        = errors.New("失効済みのトークンです")
+	ReusedRefreshToken  = errors.New("更新済みのリフレッシュトークンが再利用されました")
+	ForeignRefreshToken = errors.New("別のクライアントに発行されたリフレッシュトークンです")
+	PermissionDenied    = errors.New("操作する権限がありません")
+	NoRoleRecord        = errors.New("ロールは存在しません")
+	DisabledAccount     = errors.New("無効化されたユーザです")
+	ResetRequired       = errors.New("パスワードの再設定が必要です")
+	UnverifiedEmail     = errors.New("メールアドレスが確認されていません")
+	NoResetToken        = errors.New("パスワード再設定トークンは存在しません")
+	NoTenantRecord      = errors.New("組織は存在しません")
+	DuplicateTenant     = errors.New("組織が既に存在します")
+	MismatchTenant      = errors.New("別の組織で発行されたトークンです")
+	TooLongExpiration   = errors.New("有効期間が上限を超えています")
+	InvalidCSRFToken    = errors.New("CSRFトークンが一致しません")
+	UnknownSession      = errors.New("指定されたセッションは存在しません")
+	TooManySessions     = errors.New("同時にログインできる数の上限に達しています")
+	UnknownLimitPolicy  = errors.New("未対応の同時ログイン数の制限方式です")
+	LockTimeout         = errors.New("他の処理が完了するまで待機できませんでした")
+	InvalidBatchSize    = errors.New("1度に削除する件数は1以上にしてください")
 	InternalServerErr   = errors.New("サーバエラーが発生しました")
 )
 
@@ -37,6 +70,30 @@ var (
 	FailedCheckLogin   = errors.New("ログイン情報が確認できませんでした")
 	FailedLogin        = errors.New("ログインに失敗しました")
 	FailedLogout       = errors.New("ログアウトに失敗しました")
+	FailedRotateKey    = errors.New("署名鍵のローテーションに失敗しました")
+	FailedAuthorize    = errors.New("認可に失敗しました")
+	FailedCreateClient = errors.New("クライアント登録に失敗")
+	FailedRevokeToken  = errors.New("トークンの失効に失敗しました")
+	FailedIntrospect   = errors.New("トークンの検査に失敗しました")
+	FailedCheckOwner   = errors.New("操作権限の確認に失敗しました")
+	FailedCheckRole    = errors.New("ロールの確認に失敗しました")
+	FailedDisableUser  = errors.New("ユーザの無効化に失敗")
+	FailedEnableUser   = errors.New("ユーザの有効化に失敗")
+	FailedForceLogout  = errors.New("強制ログアウトに失敗しました")
+	FailedListSession  = errors.New("セッションの取得に失敗しました")
+	FailedRevokeSess   = errors.New("セッションの失効に失敗しました")
+	FailedCollectGC    = errors.New("期限切れデータの削除に失敗しました")
+	FailedSignOutAll   = errors.New("全ての端末からのログアウトに失敗しました")
+	FailedVerifyEmail  = errors.New("メールアドレスの確認に失敗しました")
+	FailedSendMail     = errors.New("メールの送信に失敗しました")
+	FailedForgotPass   = errors.New("パスワード再設定の受付に失敗しました")
+	FailedResetPass    = errors.New("パスワードの再設定に失敗しました")
+	FailedChangePass   = errors.New("パスワードの変更に失敗しました")
+	FailedResolveOrg   = errors.New("組織の特定に失敗しました")
+	FailedCreateOrg    = errors.New("組織の作成に失敗しました")
+	FailedIssuePAT     = errors.New("アクセストークンの発行に失敗しました")
+	FailedListPAT      = errors.New("アクセストークンの取得に失敗しました")
+	FailedRevokePAT    = errors.New("アクセストークンの失効に失敗しました")
 )
 
 func NewApplicationErr(message, detail error) ApplicationErr {
@@ -50,3 +107,6 @@ type ApplicationErr struct {
 
 func (e ApplicationErr) Error() string { return e.Message.Error() }
 func (e ApplicationErr) Unwrap() error { return e.Detail }
+
+// Is errors.Isでエラーの種類(Message)を判定できるようにする
+func (e ApplicationErr) Is(target error) bool { return e.Message == target }
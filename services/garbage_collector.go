@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"auth-test/models"
+)
+
+// gcLockName 複数のインスタンスのうち1つだけが削除を行うためのロック名
+const gcLockName = "auth_test_garbage_collection"
+
+// NewGarbageCollector batchSizeが0以下の場合は削除が終わらないため、エラーにする
+func NewGarbageCollector(
+	s models.UserSessionAccessor,
+	t models.TokenAccessor,
+	r models.PasswordResetTokenAccessor,
+	l models.Locker,
+	batchSize int,
+) (GarbageCollector, error) {
+	if batchSize <= 0 {
+		return GarbageCollector{}, NewApplicationErr(InvalidBatchSize, fmt.Errorf("件数: %d", batchSize))
+	}
+	return GarbageCollector{
+		userSessionRepo: s,
+		tokenRepo:       t,
+		resetTokenRepo:  r,
+		locker:          l,
+		batchSize:       batchSize,
+	}, nil
+}
+
+// GarbageCollector 期限切れのセッションとリフレッシュトークン、パスワード再設定トークンを削除する
+// 1度に削除する件数をbatchSizeに抑え、テーブルを長時間ロックしないようにする
+type GarbageCollector struct {
+	userSessionRepo models.UserSessionAccessor
+	tokenRepo       models.TokenAccessor
+	resetTokenRepo  models.PasswordResetTokenAccessor
+	locker          models.Locker
+	batchSize       int
+}
+
+// CollectionResult 削除した件数。他のインスタンスが実行中の場合はSkippedとなる
+type CollectionResult struct {
+	Sessions    int64
+	Tokens      int64
+	ResetTokens int64
+	Skipped     bool
+}
+
+func (g GarbageCollector) Collect(now time.Time) (*CollectionResult, error) {
+	release, ok, err := g.locker.TryLock(gcLockName)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCollectGC, err)
+	}
+	if !ok {
+		return &CollectionResult{Skipped: true}, nil
+	}
+	defer release()
+
+	sessions, err := g.deleteAll(g.userSessionRepo.DeleteExpired, now)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCollectGC, err)
+	}
+	tokens, err := g.deleteAll(g.tokenRepo.DeleteExpired, now)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCollectGC, err)
+	}
+	resetTokens, err := g.deleteAll(g.resetTokenRepo.DeleteExpired, now)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCollectGC, err)
+	}
+	return &CollectionResult{Sessions: sessions, Tokens: tokens, ResetTokens: resetTokens}, nil
+}
+
+// deleteAll 削除した件数がbatchSizeに満たなくなるまで繰り返す
+func (g GarbageCollector) deleteAll(deleteExpired func(time.Time, int) (int64, error), now time.Time) (int64, error) {
+	var total int64
+	for {
+		deleted, err := deleteExpired(now, g.batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+		if deleted < int64(g.batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+
+	"auth-test/models"
+)
+
+func NewOrganizationRegistry(repo models.OrganizationAccessor) OrganizationRegistry {
+	return OrganizationRegistry{repo: repo}
+}
+
+// OrganizationRegistry テナントの特定と登録
+type OrganizationRegistry struct {
+	repo models.OrganizationAccessor
+}
+
+// Resolve パスのプレフィックスで指定した組織を優先し、無ければHostヘッダの組織、どちらも無ければ既定の組織とする
+func (r OrganizationRegistry) Resolve(slug, host string) (*models.Organization, error) {
+	if slug != "" {
+		organization, err := r.repo.FindBySlug(slug)
+		if err != nil {
+			return nil, NewApplicationErr(FailedResolveOrg, err)
+		}
+		return organization, nil
+	}
+
+	if host != "" {
+		organization, err := r.repo.FindByHost(host)
+		if err == nil {
+			return organization, nil
+		}
+		if errors.Is(err, InternalServerErr) {
+			return nil, NewApplicationErr(FailedResolveOrg, err)
+		}
+	}
+
+	organization := models.NewOrganization(models.DefaultOrganizationID, "default", "default", "")
+	return &organization, nil
+}
+
+func (r OrganizationRegistry) Register(organization models.Organization) (*models.Organization, error) {
+	registered, err := r.repo.Insert(organization)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCreateOrg, err)
+	}
+	return registered, nil
+}
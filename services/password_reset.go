@@ -0,0 +1,131 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"auth-test/models"
+)
+
+func NewPasswordReset(
+	a models.UserAccountAccessor,
+	r models.PasswordResetTokenAccessor,
+	s models.UserSessionAccessor,
+	t models.TokenAccessor,
+	p models.PersonalAccessTokenAccessor,
+	e models.SecurityEventRecorder,
+	mailer models.Mailer,
+	resetURL string,
+	expiration time.Duration,
+) PasswordReset {
+	return PasswordReset{
+		userAccountRepo:   a,
+		resetTokenRepo:    r,
+		userSessionRepo:   s,
+		tokenRepo:         t,
+		personalTokenRepo: p,
+		eventRecorder:     e,
+		mailer:            mailer,
+		resetURL:          resetURL,
+		expiration:        expiration,
+	}
+}
+
+// PasswordReset パスワードを忘れた利用者に、メールで送信した1度だけ使えるトークンで再設定させる
+// resetURLにはトークンを受け取る画面のURLを指定し、tokenクエリを付与して送信する
+type PasswordReset struct {
+	userAccountRepo   models.UserAccountAccessor
+	resetTokenRepo    models.PasswordResetTokenAccessor
+	userSessionRepo   models.UserSessionAccessor
+	tokenRepo         models.TokenAccessor
+	personalTokenRepo models.PersonalAccessTokenAccessor
+	eventRecorder     models.SecurityEventRecorder
+	mailer            models.Mailer
+	resetURL          string
+	expiration        time.Duration
+}
+
+// Forgot 登録の有無を推測されないよう、存在しないメールアドレスや無効化されたユーザでもエラーにしない
+func (p PasswordReset) Forgot(orgID, email string, now time.Time) error {
+	account, err := p.userAccountRepo.FindByEmail(orgID, email)
+	if err != nil {
+		if errors.Is(err, NoUserEmail) {
+			return nil
+		}
+		return NewApplicationErr(FailedForgotPass, err)
+	}
+	if account.IsDisabled() {
+		return nil
+	}
+
+	random := make([]byte, 32)
+	if _, err = rand.Read(random); err != nil {
+		return NewApplicationErr(FailedForgotPass, NewApplicationErr(InternalServerErr, err))
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+	expiredAt := now.Add(p.expiration)
+
+	err = p.resetTokenRepo.Insert(
+		models.NewPasswordResetToken(models.HashPasswordResetToken(token), account.ID(), expiredAt),
+	)
+	if err != nil {
+		return NewApplicationErr(FailedForgotPass, err)
+	}
+
+	link, err := url.Parse(p.resetURL)
+	if err != nil {
+		return NewApplicationErr(FailedForgotPass, NewApplicationErr(InternalServerErr, err))
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	mail := models.NewMail(
+		account.Email(),
+		"パスワードの再設定",
+		fmt.Sprintf(
+			"以下のリンクからパスワードを再設定してください。\n\n%s\n\nリンクの有効期限は%sです。"+
+				"心当たりがない場合はこのメールを破棄してください。\n",
+			link.String(), expiredAt.Format(time.RFC3339),
+		),
+	)
+	if err = p.mailer.Send(mail); err != nil {
+		return NewApplicationErr(FailedForgotPass, NewApplicationErr(FailedSendMail, err))
+	}
+	return nil
+}
+
+// Reset パスワードを変更し、他のトークンや第三者が利用しているかもしれない全てのログインを失効させる
+func (p PasswordReset) Reset(token, password string, now time.Time) error {
+	resetToken, err := p.resetTokenRepo.Consume(models.HashPasswordResetToken(token), now.UTC())
+	if err != nil {
+		return NewApplicationErr(FailedResetPass, err)
+	}
+
+	account, err := p.userAccountRepo.Find(resetToken.AccountID())
+	if err != nil {
+		return NewApplicationErr(FailedResetPass, err)
+	}
+
+	// パスワードを更新すると再設定の要求も解除される
+	if err = p.userAccountRepo.UpdatePassword(account.ID(), password); err != nil {
+		return NewApplicationErr(FailedResetPass, err)
+	}
+
+	if err = p.resetTokenRepo.DeleteByOwner(account.ID()); err != nil {
+		return NewApplicationErr(FailedResetPass, err)
+	}
+	err = signOutEverywhere(p.userAccountRepo, p.userSessionRepo, p.tokenRepo, p.personalTokenRepo, account.ID(), now)
+	if err != nil {
+		return NewApplicationErr(FailedResetPass, err)
+	}
+
+	if err = p.eventRecorder.Record(models.NewSecurityEvent(models.EventPasswordReset, account.ID(), "", now)); err != nil {
+		return NewApplicationErr(FailedResetPass, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-test/models"
+)
+
+func NewPersonalAccessTokens(
+	repo models.PersonalAccessTokenAccessor, maxExpiration time.Duration,
+) PersonalAccessTokens {
+	return PersonalAccessTokens{repo: repo, maxExpiration: maxExpiration}
+}
+
+// PersonalAccessTokens 利用者自身によるパーソナルアクセストークンの管理
+type PersonalAccessTokens struct {
+	repo          models.PersonalAccessTokenAccessor
+	maxExpiration time.Duration
+}
+
+// Issue 発行したトークンそのものは戻り値でのみ返す
+func (t PersonalAccessTokens) Issue(
+	principal models.Principal, name, scope string, expiration time.Duration, now time.Time,
+) (*models.PersonalAccessToken, string, error) {
+	// スコープを広げたトークンを発行できないよう、パーソナルアクセストークンによる発行は認めない
+	if principal.IsRestricted() {
+		return nil, "", NewApplicationErr(
+			FailedIssuePAT, NewApplicationErr(PermissionDenied, errors.New(principal.TokenID())),
+		)
+	}
+
+	if expiration > t.maxExpiration {
+		return nil, "", NewApplicationErr(
+			FailedIssuePAT, NewApplicationErr(TooLongExpiration, fmt.Errorf("上限: %s", t.maxExpiration)),
+		)
+	}
+
+	for _, s := range strings.Fields(scope) {
+		if s != models.ScopeAll && !strings.Contains(s, ":") {
+			return nil, "", NewApplicationErr(FailedIssuePAT, NewApplicationErr(InvalidScope, errors.New(s)))
+		}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, "", NewApplicationErr(FailedIssuePAT, NewApplicationErr(InternalServerErr, err))
+	}
+	value := models.PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	issued, err := t.repo.Insert(
+		models.NewPersonalAccessToken(
+			uuid.New().String(), principal.OrganizationID(), principal.Subject(), name, scope,
+			now, now.Add(expiration), time.Time{},
+		),
+		models.HashPersonalAccessToken(value),
+	)
+	if err != nil {
+		return nil, "", NewApplicationErr(FailedIssuePAT, err)
+	}
+	return issued, value, nil
+}
+
+func (t PersonalAccessTokens) List(owner string, now time.Time) ([]models.PersonalAccessToken, error) {
+	tokens, err := t.repo.ListByOwner(owner, now)
+	if err != nil {
+		return nil, NewApplicationErr(FailedListPAT, err)
+	}
+	return tokens, nil
+}
+
+func (t PersonalAccessTokens) Revoke(owner, id string, now time.Time) error {
+	if err := t.repo.Revoke(owner, id, now); err != nil {
+		return NewApplicationErr(FailedRevokePAT, err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"auth-test/models"
+)
+
+const (
+	// LimitPolicyReject 上限に達している場合は新しいログインを拒否する
+	LimitPolicyReject = "reject"
+	// LimitPolicyEvictOldest 上限に達している場合は最も古いログインを失効させる
+	LimitPolicyEvictOldest = "evict_oldest"
+
+	sessionLimitLockName    = "auth_test_sign_in_"
+	sessionLimitLockTimeout = 5 * time.Second
+)
+
+// NewSessionLimit maxが0以下の場合は上限を設けない
+func NewSessionLimit(max int, policy string, locker models.Locker) (SessionLimit, error) {
+	switch policy {
+	case LimitPolicyReject, LimitPolicyEvictOldest:
+	default:
+		return SessionLimit{}, NewApplicationErr(UnknownLimitPolicy, errors.New(policy))
+	}
+	return SessionLimit{max: max, policy: policy, locker: locker}, nil
+}
+
+// SessionLimit 利用者ごとに同時に有効なセッションとリフレッシュトークンのファミリーの上限
+type SessionLimit struct {
+	max    int
+	policy string
+	locker models.Locker
+}
+
+// lock 同時にログインした場合も上限を超えないよう、上限の確認から登録までを利用者ごとに直列化する
+// 上限を設けない場合はロックしない
+func (l SessionLimit) lock(owner string) (func(), error) {
+	if l.max <= 0 {
+		return func() {}, nil
+	}
+	return l.locker.Lock(sessionLimitLockName+owner, sessionLimitLockTimeout)
+}
+
+// evictions 新しいログインのために失効させる古いログインの数を返す
+func (l SessionLimit) evictions(active int) (int, error) {
+	if l.max <= 0 || active < l.max {
+		return 0, nil
+	}
+	if l.policy == LimitPolicyReject {
+		return 0, NewApplicationErr(TooManySessions, fmt.Errorf("上限: %d", l.max))
+	}
+	return active - l.max + 1, nil
+}
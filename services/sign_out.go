@@ -0,0 +1,93 @@
+package services
+
+import (
+	"time"
+
+	"auth-test/models"
+)
+
+func NewSignOut(
+	a models.UserAccountAccessor,
+	s models.UserSessionAccessor,
+	t models.TokenAccessor,
+	p models.PersonalAccessTokenAccessor,
+	e models.SecurityEventRecorder,
+) SignOut {
+	return SignOut{
+		userAccountRepo:   a,
+		userSessionRepo:   s,
+		tokenRepo:         t,
+		personalTokenRepo: p,
+		eventRecorder:     e,
+	}
+}
+
+// SignOut セッションとIDトークンのいずれの方式でログインしていても、利用者の全ての資格情報を失効させる
+type SignOut struct {
+	userAccountRepo   models.UserAccountAccessor
+	userSessionRepo   models.UserSessionAccessor
+	tokenRepo         models.TokenAccessor
+	personalTokenRepo models.PersonalAccessTokenAccessor
+	eventRecorder     models.SecurityEventRecorder
+}
+
+// SignOutEverywhere パスワードの変更や端末の紛失に備え、リクエストに使用した資格情報も含めて失効させる
+func (s SignOut) SignOutEverywhere(orgID, id string, now time.Time) error {
+	if _, err := findInOrganization(s.userAccountRepo, orgID, id); err != nil {
+		return NewApplicationErr(FailedSignOutAll, err)
+	}
+
+	err := signOutEverywhere(s.userAccountRepo, s.userSessionRepo, s.tokenRepo, s.personalTokenRepo, id, now)
+	if err != nil {
+		return NewApplicationErr(FailedSignOutAll, err)
+	}
+
+	if err := s.eventRecorder.Record(models.NewSecurityEvent(models.EventSignedOutAll, id, "", now)); err != nil {
+		return NewApplicationErr(FailedSignOutAll, err)
+	}
+	return nil
+}
+
+// signOutEverywhere 先にトークンの世代を進め、途中で失敗してもIDトークンは利用できないようにする
+// パーソナルアクセストークンは一覧で失効したことを確認できるよう、削除せずに失効させる
+func signOutEverywhere(
+	a models.UserAccountAccessor,
+	s models.UserSessionAccessor,
+	t models.TokenAccessor,
+	p models.PersonalAccessTokenAccessor,
+	id string,
+	now time.Time,
+) error {
+	if err := a.IncrementTokenGeneration(id); err != nil {
+		return err
+	}
+	if err := s.DeleteByOwner(id); err != nil {
+		return err
+	}
+	if err := t.DeleteByOwner(id); err != nil {
+		return err
+	}
+	return p.RevokeByOwner(id, now.UTC())
+}
+
+// signOutOthers currentで指定したセッションのみ残し、signOutEverywhereと同じく他の資格情報を失効させる
+// セッションはトークンの世代を持たないため、世代を進めてもcurrentのセッションは利用できる
+func signOutOthers(
+	a models.UserAccountAccessor,
+	s models.UserSessionAccessor,
+	t models.TokenAccessor,
+	p models.PersonalAccessTokenAccessor,
+	id, current string,
+	now time.Time,
+) error {
+	if err := a.IncrementTokenGeneration(id); err != nil {
+		return err
+	}
+	if err := s.DeleteOthers(id, current); err != nil {
+		return err
+	}
+	if err := t.DeleteByOwner(id); err != nil {
+		return err
+	}
+	return p.RevokeByOwner(id, now.UTC())
+}
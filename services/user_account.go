@@ -1,33 +1,54 @@
 package services
 
 import (
+	"errors"
+	"time"
+
 	"auth-test/models"
 )
 
-func NewUserAccount(repo models.UserAccountAccessor) UserAccount { return UserAccount{repo: repo} }
+func NewUserAccount(
+	repo models.UserAccountAccessor,
+	s models.UserSessionAccessor,
+	t models.TokenAccessor,
+	p models.PersonalAccessTokenAccessor,
+	e models.SecurityEventRecorder,
+) UserAccount {
+	return UserAccount{
+		repo:              repo,
+		userSessionRepo:   s,
+		tokenRepo:         t,
+		personalTokenRepo: p,
+		eventRecorder:     e,
+	}
+}
 
 type UserAccount struct {
-	repo models.UserAccountAccessor
+	repo              models.UserAccountAccessor
+	userSessionRepo   models.UserSessionAccessor
+	tokenRepo         models.TokenAccessor
+	personalTokenRepo models.PersonalAccessTokenAccessor
+	eventRecorder     models.SecurityEventRecorder
 }
 
-func (a UserAccount) Find(id string) (*models.UserAccount, error) {
-	account, err := a.repo.Find(id)
+func (a UserAccount) Find(orgID, id string) (*models.UserAccount, error) {
+	account, err := findInOrganization(a.repo, orgID, id)
 	if err != nil {
 		return nil, NewApplicationErr(FailedShowUser, err)
 	}
 	return account, nil
 }
 
-func (a UserAccount) FindByEmail(email string) (*models.UserAccount, error) {
-	account, err := a.repo.FindByEmail(email)
+func (a UserAccount) FindByEmail(orgID, email string) (*models.UserAccount, error) {
+	account, err := a.repo.FindByEmail(orgID, email)
 	if err != nil {
 		return nil, NewApplicationErr(FailedShowUser, err)
 	}
 	return account, nil
 }
 
-func (a UserAccount) List() ([]models.UserAccount, error) {
-	accounts, err := a.repo.List()
+func (a UserAccount) List(orgID string) ([]models.UserAccount, error) {
+	accounts, err := a.repo.List(orgID)
 	if err != nil {
 		return nil, NewApplicationErr(FailedListUser, err)
 	}
@@ -35,14 +56,31 @@ func (a UserAccount) List() ([]models.UserAccount, error) {
 }
 
 func (a UserAccount) Create(account models.UserAccount) (*models.UserAccount, error) {
-	user, err := a.repo.Insert(account.ID(), account.Email(), account.Name(), account.Password())
+	user, err := a.repo.Insert(
+		account.OrganizationID(), account.ID(), account.Email(), account.Name(), account.Password(),
+	)
 	if err != nil {
 		return nil, NewApplicationErr(FailedCreateUser, err)
 	}
 	return user, nil
 }
 
-func (a UserAccount) Update(account models.UserAccount) (*models.UserAccount, error) {
+// Update メールアドレスと名前のみ更新する。パスワードはChangePasswordで変更する
+// メールアドレスを変更するとパスワードの再設定メールの送信先も変わるため、現在のパスワードを確認する
+// 変更したメールアドレスは確認し直すまで未確認となる
+func (a UserAccount) Update(account models.UserAccount, currentPassword string) (*models.UserAccount, error) {
+	current, err := findInOrganization(a.repo, account.OrganizationID(), account.ID())
+	if err != nil {
+		return nil, NewApplicationErr(FailedUpdateUser, err)
+	}
+
+	if current.Email() != account.Email() {
+		hash := models.NewEncryptedPassword(current.Password())
+		if err = hash.MatchWith(currentPassword); err != nil {
+			return nil, NewApplicationErr(FailedUpdateUser, NewApplicationErr(MismatchPassword, errors.New(current.ID())))
+		}
+	}
+
 	updated, err := a.repo.Update(account)
 	if err != nil {
 		return nil, NewApplicationErr(FailedUpdateUser, err)
@@ -50,9 +88,81 @@ func (a UserAccount) Update(account models.UserAccount) (*models.UserAccount, er
 	return updated, nil
 }
 
-func (a UserAccount) Delete(id string) error {
+// ChangePassword セッションを奪われただけでは変更できないよう、現在のパスワードを確認してから変更する
+// revokeOthersを指定した場合はcurrentで指定したセッション以外の全ての資格情報を失効させる
+func (a UserAccount) ChangePassword(
+	orgID, id, currentPassword, newPassword, current string, revokeOthers bool, now time.Time,
+) error {
+	account, err := findInOrganization(a.repo, orgID, id)
+	if err != nil {
+		return NewApplicationErr(FailedChangePass, err)
+	}
+
+	hash := models.NewEncryptedPassword(account.Password())
+	if err = hash.MatchWith(currentPassword); err != nil {
+		return NewApplicationErr(FailedChangePass, NewApplicationErr(MismatchPassword, errors.New(id)))
+	}
+
+	if err = a.repo.UpdatePassword(id, newPassword); err != nil {
+		return NewApplicationErr(FailedChangePass, err)
+	}
+
+	if revokeOthers {
+		err = signOutOthers(a.repo, a.userSessionRepo, a.tokenRepo, a.personalTokenRepo, id, current, now)
+		if err != nil {
+			return NewApplicationErr(FailedChangePass, err)
+		}
+	}
+
+	if err = a.eventRecorder.Record(models.NewSecurityEvent(models.EventPasswordChanged, id, "", now)); err != nil {
+		return NewApplicationErr(FailedChangePass, err)
+	}
+	return nil
+}
+
+func (a UserAccount) Delete(orgID, id string) error {
+	if _, err := findInOrganization(a.repo, orgID, id); err != nil {
+		return NewApplicationErr(FailedDeleteUser, err)
+	}
+
 	if err := a.repo.Delete(id); err != nil {
 		return NewApplicationErr(FailedDeleteUser, err)
 	}
 	return nil
 }
+
+// findInOrganization 別の組織のユーザは存在しないものとして扱う
+func findInOrganization(repo models.UserAccountAccessor, orgID, id string) (*models.UserAccount, error) {
+	account, err := repo.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.OrganizationID() != orgID {
+		return nil, NewApplicationErr(NoUserRecord, errors.New(id))
+	}
+	return account, nil
+}
+
+func NewSignInPolicy(requireVerifiedEmail bool) SignInPolicy {
+	return SignInPolicy{requireVerifiedEmail: requireVerifiedEmail}
+}
+
+// SignInPolicy パスワードの照合後に、ログインできる状態のユーザか確認する
+// requireVerifiedEmailを指定した場合はメールアドレスを確認するまでログインさせない
+type SignInPolicy struct {
+	requireVerifiedEmail bool
+}
+
+func (p SignInPolicy) check(account models.UserAccount) error {
+	switch {
+	case account.IsDisabled():
+		return NewApplicationErr(DisabledAccount, errors.New(account.ID()))
+	case account.ResetRequired():
+		return NewApplicationErr(ResetRequired, errors.New(account.ID()))
+	case p.requireVerifiedEmail && !account.EmailVerified():
+		return NewApplicationErr(UnverifiedEmail, errors.New(account.Email()))
+	default:
+		return nil
+	}
+}
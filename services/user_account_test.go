@@ -0,0 +1,75 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"auth-test/infra/memory"
+	"auth-test/models"
+	"auth-test/services"
+)
+
+func TestChangePasswordRevokesOthers(t *testing.T) {
+	tests := []struct {
+		name         string
+		revokeOthers bool
+		wantSessions int
+		wantTokens   int
+		wantGen      int
+	}{
+		{name: "他の資格情報を失効させる", revokeOthers: true, wantSessions: 1, wantTokens: 0, wantGen: 1},
+		{name: "他の資格情報を失効させない", revokeOthers: false, wantSessions: 2, wantTokens: 1, wantGen: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore()
+			accounts := memory.NewUserAccountRepository(store)
+			sessions := memory.NewUserSessionRepo(store)
+			personalTokens := memory.NewPersonalAccessTokenRepository(store)
+			now := time.Now().UTC().Truncate(time.Second)
+
+			account, err := accounts.Insert(models.DefaultOrganizationID, uuid.New().String(), testEmail, "name", testPassword)
+			assertErr(t, err, nil)
+			for _, token := range []string{"current", "other"} {
+				_, err = sessions.Register(
+					models.NewSession(account.ID(), token, "", "", now.Add(time.Hour), now.Add(time.Hour)),
+				)
+				assertErr(t, err, nil)
+			}
+			_, err = personalTokens.Insert(models.NewPersonalAccessToken(
+				uuid.New().String(), models.DefaultOrganizationID, account.ID(), "ci", "*", now, now.Add(time.Hour),
+				time.Time{},
+			), models.HashPersonalAccessToken(uuid.New().String()))
+			assertErr(t, err, nil)
+
+			service := services.NewUserAccount(
+				accounts, sessions, memory.NewTokenRepository(store), personalTokens, &eventRecorder{},
+			)
+			err = service.ChangePassword(
+				models.DefaultOrganizationID, account.ID(), testPassword, testPassword+"2", "current", tt.revokeOthers,
+				now,
+			)
+			assertErr(t, err, nil)
+
+			// リクエストに使用したセッションは残し、発行済みのIDトークンとパーソナルアクセストークンは失効させる
+			remaining, err := sessions.ListByOwner(account.ID())
+			assertErr(t, err, nil)
+			if len(remaining) != tt.wantSessions || remaining[0].Token() != "current" {
+				t.Fatalf("残ったセッションが一致しません: %+v", remaining)
+			}
+			tokens, err := personalTokens.ListByOwner(account.ID(), now)
+			assertErr(t, err, nil)
+			if len(tokens) != tt.wantTokens {
+				t.Fatalf("有効なパーソナルアクセストークンの数が一致しません: want %d, got %d", tt.wantTokens, len(tokens))
+			}
+			changed, err := accounts.Find(account.ID())
+			assertErr(t, err, nil)
+			if changed.TokenGeneration() != account.TokenGeneration()+tt.wantGen {
+				t.Fatalf("トークンの世代が一致しません: %d", changed.TokenGeneration())
+			}
+		})
+	}
+}
@@ -2,39 +2,60 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"auth-test/models"
 )
 
 type Session interface {
-	Sign(string, string, string, time.Time) (string, error)
+	Sign(string, string, string, string, string, string, time.Time) (string, error)
 	Verify(string) error
-	FindOwner(string, string) error
+	Authenticate(string, string, time.Time) (*models.Principal, error)
 	SignOut(string, string) error
 }
 
 func NewSessionAuthorization(
 	a models.UserAccountAccessor,
 	s models.UserSessionAccessor,
+	r models.RoleAccessor,
+	e models.SecurityEventRecorder,
+	limit SessionLimit,
+	signIn SignInPolicy,
 	expiration time.Duration,
+	idle time.Duration,
+	touch time.Duration,
 
 ) UserSession {
 	return UserSession{
 		userAccountRepo: a,
 		userSessionRepo: s,
+		roleRepo:        r,
+		eventRecorder:   e,
+		limit:           limit,
+		signIn:          signIn,
 		expiration:      expiration,
+		idle:            idle,
+		touch:           touch,
 	}
 }
 
+// UserSession expirationはログインからの最大の有効期間、idleは無操作で失効するまでの期間
+// touchより短い間隔のリクエストでは期限の延長をDBに書き込まない
 type UserSession struct {
 	userAccountRepo models.UserAccountAccessor
 	userSessionRepo models.UserSessionAccessor
+	roleRepo        models.RoleAccessor
+	eventRecorder   models.SecurityEventRecorder
+	limit           SessionLimit
+	signIn          SignInPolicy
 	expiration      time.Duration
+	idle            time.Duration
+	touch           time.Duration
 }
 
-func (s UserSession) Sign(email, password, sessionID string, now time.Time) (string, error) {
-	account, err := s.userAccountRepo.FindByEmail(email)
+func (s UserSession) Sign(orgID, email, password, sessionID, ip, userAgent string, now time.Time) (string, error) {
+	account, err := s.userAccountRepo.FindByEmail(orgID, email)
 	if err != nil {
 		return "", NewApplicationErr(FailedLogin, err)
 	}
@@ -43,9 +64,48 @@ func (s UserSession) Sign(email, password, sessionID string, now time.Time) (str
 	if err = hash.MatchWith(password); err != nil {
 		return "", NewApplicationErr(FailedLogin, errors.New("パスワードの検証に失敗"))
 	}
+	if err = s.signIn.check(*account); err != nil {
+		return "", NewApplicationErr(FailedLogin, err)
+	}
+
+	release, err := s.limit.lock(account.ID())
+	if err != nil {
+		return "", NewApplicationErr(FailedLogin, err)
+	}
+	defer release()
+
+	if err = s.evict(account.ID(), now); err != nil {
+		return "", NewApplicationErr(FailedLogin, err)
+	}
 
-	sess := models.NewSession(account.ID(), sessionID, now.Add(s.expiration))
-	return s.userSessionRepo.Register(sess)
+	sess := models.NewSession(account.ID(), sessionID, ip, userAgent, now, now.Add(s.expiration))
+	return s.userSessionRepo.Register(sess.Slide(now, s.idle))
+}
+
+// evict 同時ログイン数の上限に達している場合、ログインした順に古いセッションを削除する
+func (s UserSession) evict(owner string, now time.Time) error {
+	sessions, err := s.userSessionRepo.ListByOwner(owner)
+	if err != nil {
+		return err
+	}
+
+	n, err := s.limit.evictions(len(sessions))
+	if err != nil {
+		return err
+	}
+	for _, sess := range sessions[:n] {
+		if err = s.userSessionRepo.Delete(owner, sess.Token()); err != nil {
+			return err
+		}
+
+		event := models.NewSecurityEvent(
+			models.EventSessionEvicted, owner, fmt.Sprintf("session: %s", models.Fingerprint(sess.Token())), now,
+		)
+		if err = s.eventRecorder.Record(event); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s UserSession) Verify(token string) error {
@@ -57,19 +117,94 @@ func (s UserSession) Verify(token string) error {
 	return nil
 }
 
-func (s UserSession) FindOwner(id, token string) error {
-	owner, err := s.userSessionRepo.FindOwner(token)
+// Authenticate セッショントークンの持ち主をリクエストの主体として返す
+// 別の組織のユーザのセッションは存在しないものとして扱う
+func (s UserSession) Authenticate(orgID, token string, now time.Time) (*models.Principal, error) {
+	sess, err := s.userSessionRepo.FindOwner(token, now)
 	if err != nil {
-		return NewApplicationErr(FailedCheckLogin, err)
+		return nil, NewApplicationErr(FailedCheckLogin, err)
 	}
+	owner := sess.Owner()
 
-	if owner != id {
-		return NewApplicationErr(
-			FailedCheckLogin,
-			NewApplicationErr(InvalidLoginSession, errors.New(id)),
-		)
+	account, err := s.userAccountRepo.Find(owner)
+	if err != nil {
+		return nil, NewApplicationErr(FailedCheckLogin, err)
+	}
+	if account.OrganizationID() != orgID {
+		return nil, NewApplicationErr(FailedCheckLogin, NewApplicationErr(NoSessionRecord, errors.New(orgID)))
+	}
+	if account.IsDisabled() {
+		return nil, NewApplicationErr(FailedCheckLogin, NewApplicationErr(DisabledAccount, errors.New(owner)))
+	}
+
+	// セッションはトークンにロールを持たないため、認証の都度取得する
+	roles, err := s.roleRepo.FindByAccount(account.ID())
+	if err != nil {
+		return nil, NewApplicationErr(FailedCheckLogin, err)
+	}
+
+	if err = s.extend(*sess, now); err != nil {
+		return nil, NewApplicationErr(FailedCheckLogin, err)
+	}
+
+	principal := models.NewPrincipal(orgID, account.ID(), account.Email(), []string{}, "", roles)
+	return &principal, nil
+}
+
+// extend 前回の延長からtouch以上経過した場合のみ無操作の期限を延長する
+func (s UserSession) extend(sess models.Session, now time.Time) error {
+	next := sess.Slide(now, s.idle).ExpiredAt()
+	if next.Sub(sess.ExpiredAt()) < s.touch {
+		return nil
 	}
+	return s.userSessionRepo.Extend(sess.Token(), now, next)
+}
+
+// Sessions 有効なセッションをログインした順に返す
+func (s UserSession) Sessions(orgID, owner string) ([]models.Session, error) {
+	if _, err := findInOrganization(s.userAccountRepo, orgID, owner); err != nil {
+		return nil, NewApplicationErr(FailedListSession, err)
+	}
+
+	sessions, err := s.userSessionRepo.ListByOwner(owner)
+	if err != nil {
+		return nil, NewApplicationErr(FailedListSession, err)
+	}
+	return sessions, nil
+}
 
+// Revoke セッショントークンそのものは利用者に返さないため、フィンガープリントで失効させるセッションを指定する
+func (s UserSession) Revoke(orgID, owner, fingerprint string) error {
+	if _, err := findInOrganization(s.userAccountRepo, orgID, owner); err != nil {
+		return NewApplicationErr(FailedRevokeSess, err)
+	}
+
+	sessions, err := s.userSessionRepo.ListByOwner(owner)
+	if err != nil {
+		return NewApplicationErr(FailedRevokeSess, err)
+	}
+
+	for _, sess := range sessions {
+		if models.Fingerprint(sess.Token()) != fingerprint {
+			continue
+		}
+		if err = s.userSessionRepo.Delete(owner, sess.Token()); err != nil {
+			return NewApplicationErr(FailedRevokeSess, err)
+		}
+		return nil
+	}
+	return NewApplicationErr(FailedRevokeSess, NewApplicationErr(UnknownSession, errors.New(fingerprint)))
+}
+
+// SignOutOthers currentで指定したセッション以外からログアウトする
+func (s UserSession) SignOutOthers(orgID, owner, current string) error {
+	if _, err := findInOrganization(s.userAccountRepo, orgID, owner); err != nil {
+		return NewApplicationErr(FailedLogout, err)
+	}
+
+	if err := s.userSessionRepo.DeleteOthers(owner, current); err != nil {
+		return NewApplicationErr(FailedLogout, err)
+	}
 	return nil
 }
 